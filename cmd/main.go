@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/api"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/controllers"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/initialisers"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
@@ -10,12 +11,18 @@ import (
 func init() {
 	initialisers.LoadEnv()
 	utils.InitLogger()
+	if err := utils.LoadJWTKeys(); err != nil {
+		utils.Logger.Fatal("Failed to load JWT keys: ", err)
+	}
 	db.ConnectDB()
 }
 
 func main() {
 	r := api.SetUpRoutes()
 
+	// Applies scheduled price changes as they fall due
+	go controllers.RunPriceScheduler()
+
 	utils.Logger.Info("Smart Retail server starting on http://localhost:8080")
 	r.Run(":8080")
 }
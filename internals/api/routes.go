@@ -1,13 +1,18 @@
 package api
 
 import (
+	"strings"
+
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/controllers"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/middlewares"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
 	"github.com/gin-gonic/gin"
 )
 
 func SetUpRoutes() *gin.Engine {
 	r := gin.Default()
+	can := middlewares.RequirePermission
 
 	// Configure trusted proxies for security
 	// If not using a proxy/load balancer, disable proxy trust:
@@ -23,6 +28,14 @@ func SetUpRoutes() *gin.Engine {
 	// logging middleware
 	r.Use(middlewares.RequestLogger())
 
+	// public keys for verifying our access tokens
+	r.GET("/.well-known/jwks.json", controllers.GetJWKS)
+
+	// uploaded images, when they are kept on the local filesystem
+	if local, ok := services.NewStorage().(*services.LocalStorage); ok && strings.HasPrefix(local.BaseURL, "/") {
+		r.Static(local.BaseURL, local.Dir)
+	}
+
 	api := r.Group("/api/v1")
 
 	// health endpoint
@@ -37,13 +50,35 @@ func SetUpRoutes() *gin.Engine {
 	// auth routes (no authentication required)
 	auth.POST("/register", controllers.Register)
 	auth.POST("/login", controllers.Login)
+	auth.POST("/login/otp/request", controllers.RequestLoginOTP)
+	auth.POST("/login/otp/verify", controllers.VerifyLoginOTP)
+	auth.POST("/login/2fa", controllers.VerifyTwoFactorLogin)
+	auth.POST("/refresh", controllers.RefreshSession)
+	auth.POST("/verify-email/confirm", controllers.ConfirmEmailVerification)
+	auth.POST("/password-reset/request", controllers.RequestPasswordReset)
+	auth.POST("/password-reset/confirm", controllers.ConfirmPasswordReset)
 
 	// auth routes requiring authentication
 	authProtected := auth.Group("/")
 	authProtected.Use(middlewares.JWTAuthMiddleware())
 	{
 		authProtected.GET("/me", controllers.GetCurrentUser)
+		authProtected.PUT("/me", controllers.UpdateProfile)
+		authProtected.GET("/me/activity", controllers.GetAccountActivity)
+		authProtected.GET("/me/export", controllers.ExportAccountData)
+		authProtected.DELETE("/me", controllers.DeleteAccount)
 		authProtected.POST("/logout", controllers.Logout)
+		authProtected.GET("/sessions", controllers.GetSessions)
+		authProtected.DELETE("/sessions", controllers.RevokeAllSessions)
+		authProtected.DELETE("/sessions/:id", controllers.RevokeSession)
+		authProtected.POST("/change-password", controllers.ChangePassword)
+		authProtected.POST("/verify-email/request", controllers.RequestEmailVerification)
+		authProtected.POST("/verify-phone/request", controllers.RequestPhoneVerification)
+		authProtected.POST("/verify-phone/confirm", controllers.ConfirmPhoneVerification)
+		authProtected.POST("/2fa/setup", controllers.SetupTwoFactor)
+		authProtected.POST("/2fa/enable", controllers.EnableTwoFactor)
+		authProtected.POST("/2fa/disable", controllers.DisableTwoFactor)
+		authProtected.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
 	}
 
 	// M-Pesa webhook (no authentication required)
@@ -55,9 +90,22 @@ func SetUpRoutes() *gin.Engine {
 	{
 		// Product routes (accessible by all authenticated users)
 		protected.GET("/products", controllers.GetAllProducts)
+		protected.GET("/products/search", controllers.SearchProducts)
+		protected.GET("/products/barcode/:code", controllers.LookupBarcode)
+		protected.GET("/categories", controllers.GetCategories)
+		protected.GET("/tags", controllers.GetTags)
+		protected.GET("/brands", controllers.GetBrands)
 		protected.GET("/products/:id", controllers.GetProduct)
+		protected.GET("/products/:id/images", controllers.GetProductImages)
 		protected.GET("/products/branch/:branchId", controllers.GetBranchInventory)
 
+		// Product reviews; customers review products from their completed orders
+		protected.GET("/products/:id/reviews", controllers.GetProductReviews)
+		protected.POST("/products/:id/reviews", controllers.CreateReview)
+		protected.GET("/reviews/mine", controllers.GetMyReviews)
+		protected.PUT("/reviews/:id", controllers.UpdateMyReview)
+		protected.DELETE("/reviews/:id", controllers.DeleteMyReview)
+
 		// Branch routes (accessible by all authenticated users)
 		protected.GET("/branches", controllers.GetAllBranches)
 		protected.GET("/branches/:id", controllers.GetBranch)
@@ -67,34 +115,129 @@ func SetUpRoutes() *gin.Engine {
 		protected.GET("/orders", controllers.GetUserOrders)
 		protected.GET("/orders/:id", controllers.GetOrderById)
 
+		// Saved delivery addresses
+		protected.GET("/addresses", controllers.GetAddresses)
+		protected.POST("/addresses", controllers.CreateAddress)
+		protected.PUT("/addresses/:id", controllers.UpdateAddress)
+		protected.DELETE("/addresses/:id", controllers.DeleteAddress)
+		protected.POST("/addresses/:id/default", controllers.SetDefaultAddress)
+
 		// Payment routes (customer accessible)
 		protected.POST("/payments/mpesa/initiate", controllers.InitiateMpesaPayment)
 		protected.GET("/payments/:orderId/status", controllers.GetPaymentStatus)
 
-		// admin only routes
+		// Restock requests (branch staff and admins)
+		protected.POST("/restock-requests", can(permissions.RequestRestock), controllers.CreateRestockRequest)
+		protected.GET("/restock-requests", can(permissions.RequestRestock), controllers.GetMyRestockRequests)
+		protected.POST("/restock-requests/:id/cancel", can(permissions.RequestRestock), controllers.CancelRestockRequest)
+
+		// staff and admin routes, each gated by a permission (see internals/permissions);
+		// branch-scoped roles only see their assigned branches
 		admin := protected.Group("/admin")
-		admin.Use(middlewares.AdminAuthMiddleware())
 		{
 			// Branch management
-			admin.POST("/branches", controllers.CreateBranch)
-			admin.PUT("/branches/:id", controllers.UpdateBranch)
-			admin.DELETE("/branches/:id", controllers.DeleteBranch)
+			admin.POST("/branches", can(permissions.ManageBranches), controllers.CreateBranch)
+			admin.PATCH("/branches/:id", can(permissions.ManageBranches), controllers.UpdateBranch)
+			admin.PUT("/branches/:id", can(permissions.ManageBranches), controllers.UpdateBranch) // Older clients; If-Match optional
+			admin.GET("/branches/:id/changes", can(permissions.ManageBranches), controllers.GetBranchChanges)
+			admin.DELETE("/branches/:id", can(permissions.ManageBranches), controllers.DeleteBranch)
+			admin.GET("/branch-locations", can(permissions.ManageBranches), controllers.GetBranchLocations)
+			admin.POST("/branch-locations", can(permissions.ManageBranches), controllers.CreateBranchLocation)
+			admin.PUT("/branch-locations/:id", can(permissions.ManageBranches), controllers.UpdateBranchLocation)
+			admin.DELETE("/branch-locations/:id", can(permissions.ManageBranches), controllers.DeleteBranchLocation)
 
 			// Product management
-			admin.POST("/products", controllers.CreateProduct)
-			admin.PUT("/products/:id", controllers.UpdateProduct)
-			admin.DELETE("/products/:id", controllers.DeleteProduct)
-			admin.GET("/products/brand", controllers.GetProductsByBrand)
-			admin.GET("/products/:id/stock", controllers.GetProductStockAcrossBranches)
+			admin.POST("/products", can(permissions.ManageProducts), controllers.CreateProduct)
+			admin.PATCH("/products/:id", can(permissions.ManageProducts), controllers.UpdateProduct)
+			admin.PUT("/products/:id", can(permissions.ManageProducts), controllers.UpdateProduct) // Older clients; If-Match optional
+			admin.GET("/products/:id/changes", can(permissions.ManageProducts), controllers.GetProductChanges)
+			admin.DELETE("/products/:id", can(permissions.ManageProducts), controllers.DeleteProduct)
+			admin.GET("/products/brand", can(permissions.ManageProducts), controllers.GetProductsByBrand)
+			admin.PUT("/products/:id/tags", can(permissions.ManageProducts), controllers.SetProductTags)
+			admin.POST("/products/:id/images", can(permissions.ManageProducts), controllers.UploadProductImage)
+			admin.PUT("/products/:id/images/order", can(permissions.ManageProducts), controllers.ReorderProductImages)
+			admin.DELETE("/products/:id/images/:imageId", can(permissions.ManageProducts), controllers.DeleteProductImage)
+			admin.POST("/products/:id/variants", can(permissions.ManageProducts), controllers.CreateVariant)
+			admin.PUT("/variants/:id", can(permissions.ManageProducts), controllers.UpdateVariant)
+			admin.DELETE("/variants/:id", can(permissions.ManageProducts), controllers.DeleteVariant)
+			admin.POST("/variants/:id/scheduled-prices", can(permissions.ManageProducts), controllers.SchedulePriceChange)
+			admin.GET("/scheduled-prices", can(permissions.ManageProducts), controllers.GetScheduledPriceChanges)
+			admin.DELETE("/scheduled-prices/:id", can(permissions.ManageProducts), controllers.CancelScheduledPriceChange)
+			admin.GET("/products/:id/prices", can(permissions.ViewReports), controllers.GetPriceTimeline)
+			admin.GET("/products/:id/stock", can(permissions.ViewInventory), controllers.GetProductStockAcrossBranches)
+
+			// Category and tag management
+			admin.POST("/categories", can(permissions.ManageProducts), controllers.CreateCategory)
+			admin.PUT("/categories/:id", can(permissions.ManageProducts), controllers.UpdateCategory)
+			admin.DELETE("/categories/:id", can(permissions.ManageProducts), controllers.DeleteCategory)
+			admin.POST("/tags", can(permissions.ManageProducts), controllers.CreateTag)
+			admin.PUT("/tags/:id", can(permissions.ManageProducts), controllers.UpdateTag)
+			admin.DELETE("/tags/:id", can(permissions.ManageProducts), controllers.DeleteTag)
+
+			// Review moderation
+			admin.GET("/reviews", can(permissions.ModerateReviews), controllers.GetReviewsForModeration)
+			admin.POST("/reviews/:id/approve", can(permissions.ModerateReviews), controllers.ApproveReview)
+			admin.POST("/reviews/:id/reject", can(permissions.ModerateReviews), controllers.RejectReview)
+
+			// Brand management
+			admin.GET("/brands", can(permissions.ManageProducts), controllers.GetAllBrands)
+			admin.POST("/brands", can(permissions.ManageProducts), controllers.CreateBrand)
+			admin.PUT("/brands/:id", can(permissions.ManageProducts), controllers.UpdateBrand)
+			admin.DELETE("/brands/:id", can(permissions.ManageProducts), controllers.DeleteBrand)
 
 			// Restocking
-			admin.POST("/restock", controllers.RestockBranch)
-			admin.GET("/inventory", controllers.GetInventory)
-			admin.GET("/restock-logs", controllers.GetRestockLogs)
+			admin.POST("/restock", can(permissions.RestockInventory), controllers.RestockBranch)
+			admin.POST("/restock/bulk", can(permissions.RestockInventory), controllers.BulkRestock)
+			admin.GET("/inventory", can(permissions.ViewInventory), controllers.GetInventory)
+			admin.GET("/inventory/export", can(permissions.ViewInventory), controllers.ExportInventoryCSV)
+			admin.GET("/restock-logs", can(permissions.ViewInventory), controllers.GetRestockLogs)
+			admin.GET("/restock-requests", can(permissions.ReviewRestockRequests), controllers.GetRestockRequests)
+			admin.GET("/restock-requests/:id", can(permissions.ReviewRestockRequests), controllers.GetRestockRequest)
+			admin.POST("/restock-requests/:id/approve", can(permissions.ReviewRestockRequests), controllers.ApproveRestockRequest)
+			admin.POST("/restock-requests/:id/reject", can(permissions.ReviewRestockRequests), controllers.RejectRestockRequest)
+			admin.GET("/inventory/batches", can(permissions.ViewInventory), controllers.GetInventoryBatches)
+			admin.GET("/inventory/near-expiry", can(permissions.ViewInventory), controllers.GetNearExpiryReport)
+			admin.POST("/inventory/write-off-expired", can(permissions.WriteOffInventory), controllers.WriteOffExpiredBatches)
+
+			// Suppliers
+			admin.POST("/suppliers", can(permissions.ManagePurchasing), controllers.CreateSupplier)
+			admin.GET("/suppliers", can(permissions.ManagePurchasing), controllers.GetAllSuppliers)
+			admin.GET("/suppliers/:id", can(permissions.ManagePurchasing), controllers.GetSupplier)
+			admin.PUT("/suppliers/:id", can(permissions.ManagePurchasing), controllers.UpdateSupplier)
+			admin.DELETE("/suppliers/:id", can(permissions.ManagePurchasing), controllers.DeleteSupplier)
+
+			// Purchase orders and goods received
+			admin.POST("/purchase-orders", can(permissions.ManagePurchasing), controllers.CreatePurchaseOrder)
+			admin.GET("/purchase-orders", can(permissions.ManagePurchasing), controllers.GetPurchaseOrders)
+			admin.GET("/purchase-orders/:id", can(permissions.ManagePurchasing), controllers.GetPurchaseOrder)
+			admin.POST("/purchase-orders/:id/cancel", can(permissions.ManagePurchasing), controllers.CancelPurchaseOrder)
+			admin.POST("/purchase-orders/:id/receipts", can(permissions.ManagePurchasing), controllers.ReceivePurchaseOrder)
+			admin.GET("/purchase-orders/:id/receipts", can(permissions.ManagePurchasing), controllers.GetPurchaseOrderReceipts)
+
+			// Orders
+			admin.GET("/orders", can(permissions.ViewOrders), controllers.GetBranchOrders)
+			admin.PUT("/orders/:id/status", can(permissions.ManageOrders), controllers.UpdateOrderStatus)
 
 			// Reports
-			admin.GET("/reports/sales", controllers.GetSalesReports)
-			admin.GET("/reports/branch/:branchId", controllers.GetBranchReport)
+			admin.GET("/reports/sales", can(permissions.ViewReports), controllers.GetSalesReports)
+			admin.GET("/reports/branch/:branchId", can(permissions.ViewReports), controllers.GetBranchReport)
+			admin.GET("/reports/margin", can(permissions.ViewReports), controllers.GetMarginReport)
+
+			// User management
+			admin.GET("/users", can(permissions.ManageUsers), controllers.GetUsers)
+			admin.POST("/users/invite", can(permissions.ManageUsers), controllers.InviteUser)
+			admin.GET("/users/:id", can(permissions.ManageUsers), controllers.GetUser)
+			admin.PUT("/users/:id/access", can(permissions.ManageUsers), controllers.UpdateUserAccess)
+			admin.POST("/users/:id/disable", can(permissions.ManageUsers), controllers.DisableUser)
+			admin.POST("/users/:id/enable", can(permissions.ManageUsers), controllers.EnableUser)
+			admin.POST("/users/:id/force-password-reset", can(permissions.ManageUsers), controllers.ForcePasswordReset)
+			admin.GET("/login-locks", can(permissions.ManageUsers), controllers.GetLoginLocks)
+			admin.DELETE("/login-locks/:id", can(permissions.ManageUsers), controllers.ClearLoginLock)
+
+			// API keys for machine clients
+			admin.POST("/api-keys", can(permissions.ManageUsers), controllers.CreateAPIKey)
+			admin.GET("/api-keys", can(permissions.ManageUsers), controllers.GetAPIKeys)
+			admin.DELETE("/api-keys/:id", can(permissions.ManageUsers), controllers.RevokeAPIKey)
 		}
 	}
 
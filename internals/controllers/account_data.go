@@ -0,0 +1,305 @@
+// personal data export and account deletion controller
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportAccountData returns everything held about the caller as a single
+// JSON download, for data subject access requests.
+func ExportAccountData(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.Preload("Branches").First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var addresses []models.Address
+	var orders []models.Order
+	var payments []models.Payment
+	var sessions []models.Session
+	var activities []models.AccountActivity
+	var reviews []models.Review
+
+	if err := db.DB.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&addresses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export addresses"})
+		return
+	}
+	if err := db.DB.Preload("Branch").Preload("OrderItems.Product").Preload("OrderItems.Variant").
+		Where("user_id = ?", user.ID).Order("created_at ASC").Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export orders"})
+		return
+	}
+	if err := db.DB.Where("order_id IN (?)", db.DB.Model(&models.Order{}).Select("id").Where("user_id = ?", user.ID)).
+		Order("created_at ASC").Find(&payments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export payments"})
+		return
+	}
+	if err := db.DB.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export sessions"})
+		return
+	}
+	if err := db.DB.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export account activity"})
+		return
+	}
+	if err := db.DB.Preload("User").Preload("Product").Where("user_id = ?", user.ID).Order("created_at ASC").Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export reviews"})
+		return
+	}
+
+	orderData := []gin.H{}
+	for _, order := range orders {
+		items := []gin.H{}
+		for _, item := range order.OrderItems {
+			items = append(items, gin.H{
+				"productId":   item.ProductID,
+				"productName": item.Product.Name,
+				"variantId":   item.VariantID,
+				"variant":     item.Variant.Name,
+				"brand":       item.ProductBrand,
+				"quantity":    item.Quantity,
+				"price":       item.Price,
+				"subtotal":    item.Subtotal,
+			})
+		}
+
+		orderData = append(orderData, gin.H{
+			"id":              order.ID,
+			"branch":          order.Branch.Name,
+			"items":           items,
+			"totalAmount":     order.TotalAmount,
+			"orderStatus":     order.OrderStatus,
+			"paymentStatus":   order.PaymentStatus,
+			"paymentMethod":   order.PaymentMethod,
+			"deliveryAddress": order.DeliveryAddress,
+			"createdAt":       order.CreatedAt,
+			"completedAt":     order.CompletedAt,
+		})
+	}
+
+	paymentData := []gin.H{}
+	for _, payment := range payments {
+		paymentData = append(paymentData, gin.H{
+			"id":            payment.ID,
+			"orderId":       payment.OrderID,
+			"phone":         payment.Phone,
+			"amount":        payment.Amount,
+			"transactionId": payment.TransactionID,
+			"status":        payment.Status,
+			"mpesaResponse": payment.MpesaResponse,
+			"createdAt":     payment.CreatedAt,
+		})
+	}
+
+	sessionData := []gin.H{}
+	for _, session := range sessions {
+		sessionData = append(sessionData, gin.H{
+			"id":         session.ID,
+			"userAgent":  session.UserAgent,
+			"ipAddress":  session.IPAddress,
+			"createdAt":  session.CreatedAt,
+			"lastUsedAt": session.LastUsedAt,
+			"revokedAt":  session.RevokedAt,
+		})
+	}
+
+	activityData := []gin.H{}
+	for _, activity := range activities {
+		activityData = append(activityData, gin.H{
+			"event":     activity.Event,
+			"details":   activity.Details,
+			"ipAddress": activity.IPAddress,
+			"userAgent": activity.UserAgent,
+			"createdAt": activity.CreatedAt,
+		})
+	}
+
+	reviewData := []gin.H{}
+	for _, review := range reviews {
+		reviewData = append(reviewData, reviewDetails(review))
+	}
+
+	profile := userSummary(user)
+	profile["preferredBranchId"] = user.PreferredBranchID
+
+	recordActivity(c, user.ID, "data_exported", "")
+
+	filename := fmt.Sprintf("drinx-account-data-%s.json", time.Now().Format("20060102"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"exportedAt": time.Now(),
+		"profile":    profile,
+		"addresses":  addresses,
+		"orders":     orderData,
+		"payments":   paymentData,
+		"sessions":   sessionData,
+		"activity":   activityData,
+		"reviews":    reviewData,
+	})
+}
+
+// DeleteAccount erases a customer's personal data on request. Orders and
+// payments are kept for accounting, but are detached from anything that
+// identifies the customer: the account row stays as an anonymous placeholder,
+// delivery addresses on orders are cleared and payment phone numbers masked.
+func DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Your password is required to delete your account"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.Role != permissions.RoleCustomer {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Staff accounts must be removed by an administrator"})
+		return
+	}
+
+	if !utils.CheckPassword(body.Password, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	var openOrders int64
+	if err := db.DB.Model(&models.Order{}).Where("user_id = ? AND order_status = ?", user.ID, "processing").
+		Count(&openOrders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check your orders"})
+		return
+	}
+	if openOrders > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have orders in progress; please wait until they are completed or cancelled"})
+		return
+	}
+
+	placeholder, err := utils.GenerateRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	originalEmail, originalName := user.Email, user.Name
+	now := time.Now()
+	tx := db.DB.Begin()
+
+	fail := func(message string) {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+
+	// Masks all but the first 4 and last 2 digits, e.g. 2547******78
+	if err := tx.Exec(`UPDATE payments
+		SET mpesa_response = REPLACE(mpesa_response, phone, LEFT(phone, 4) || '******' || RIGHT(phone, 2)),
+			phone = LEFT(phone, 4) || '******' || RIGHT(phone, 2)
+		WHERE order_id IN (SELECT id FROM orders WHERE user_id = ?) AND phone NOT LIKE '%*%'`, user.ID).Error; err != nil {
+		fail("Failed to anonymise payments")
+		return
+	}
+
+	if err := tx.Model(&models.Order{}).Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"delivery_address": "", "address_id": nil}).Error; err != nil {
+		fail("Failed to anonymise orders")
+		return
+	}
+
+	// Reviews are the customer's own words, so they go too
+	var reviewedProductIDs []string
+	if err := tx.Model(&models.Review{}).Where("user_id = ?", user.ID).
+		Pluck("product_id", &reviewedProductIDs).Error; err != nil {
+		fail("Failed to delete reviews")
+		return
+	}
+
+	for _, record := range []interface{}{
+		&models.Review{},
+		&models.Address{},
+		&models.UserToken{},
+		&models.TwoFactorRecoveryCode{},
+		&models.AccountActivity{},
+		&models.Session{},
+	} {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(record).Error; err != nil {
+			fail("Failed to delete account data")
+			return
+		}
+	}
+
+	if len(reviewedProductIDs) > 0 {
+		if err := refreshProductRating(tx, reviewedProductIDs...); err != nil {
+			fail("Failed to update product ratings")
+			return
+		}
+	}
+
+	if err := tx.Where("scope = ? AND key = ?", "account", loginAccountKey(user.Email)).
+		Unscoped().Delete(&models.LoginThrottle{}).Error; err != nil {
+		fail("Failed to delete account data")
+		return
+	}
+
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"name":                    "Deleted user",
+		"email":                   fmt.Sprintf("deleted-%s@users.invalid", user.ID),
+		"phone":                   "",
+		"password":                utils.HashPassword(placeholder),
+		"email_verified_at":       nil,
+		"phone_verified_at":       nil,
+		"totp_secret":             "",
+		"totp_last_step":          0,
+		"two_factor_enabled_at":   nil,
+		"preferred_branch_id":     nil,
+		"password_reset_required": false,
+		"disabled_at":             now,
+		"anonymised_at":           now,
+	}).Error; err != nil {
+		fail("Failed to anonymise account")
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+
+	if err := services.NewNotifier().Send(services.Notification{
+		Channel: "email",
+		To:      originalEmail,
+		Subject: "Your account has been deleted",
+		Body: fmt.Sprintf("Hi %s,\n\nYour Drinx account and personal data have been deleted as you requested. Records of past orders and payments are kept without your details, as required for accounting.",
+			originalName),
+	}); err != nil {
+		utils.Logger.WithError(err).Error("Failed to send account deletion confirmation")
+	}
+
+	clearAuthCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Your account has been deleted"})
+}
@@ -0,0 +1,267 @@
+// saved addresses controller
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxSavedAddresses = 20
+
+// formatAddress renders an address on one line for orders and receipts.
+func formatAddress(address models.Address) string {
+	parts := []string{address.RecipientName, address.Phone, address.Line1}
+	if address.Line2 != "" {
+		parts = append(parts, address.Line2)
+	}
+	parts = append(parts, address.City)
+	if address.Notes != "" {
+		parts = append(parts, "("+address.Notes+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// setDefaultAddress makes addressID the user's only default address.
+func setDefaultAddress(tx *gorm.DB, userID, addressID string) error {
+	if err := tx.Model(&models.Address{}).
+		Where("user_id = ? AND id <> ? AND is_default = ?", userID, addressID, true).
+		Update("is_default", false).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Address{}).
+		Where("user_id = ? AND id = ?", userID, addressID).
+		Update("is_default", true).Error
+}
+
+func GetAddresses(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var addresses []models.Address
+	if err := db.DB.Where("user_id = ?", userID).
+		Order("is_default DESC, created_at ASC").
+		Find(&addresses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch addresses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, addresses)
+}
+
+// CreateAddress saves a delivery address. The first address saved becomes
+// the default.
+func CreateAddress(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Label         string `json:"label" binding:"required,max=50"`
+		RecipientName string `json:"recipientName" binding:"required,max=100"`
+		Phone         string `json:"phone" binding:"required"`
+		Line1         string `json:"line1" binding:"required,max=255"`
+		Line2         string `json:"line2" binding:"max=255"`
+		City          string `json:"city" binding:"required,max=100"`
+		Notes         string `json:"notes" binding:"max=255"`
+		IsDefault     bool   `json:"isDefault"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	phone, err := utils.NormalizePhone(body.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	db.DB.Model(&models.Address{}).Where("user_id = ?", userID).Count(&count)
+	if count >= maxSavedAddresses {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can save at most 20 addresses"})
+		return
+	}
+
+	address := models.Address{
+		UserID:        userID.(string),
+		Label:         body.Label,
+		RecipientName: body.RecipientName,
+		Phone:         phone,
+		Line1:         body.Line1,
+		Line2:         body.Line2,
+		City:          body.City,
+		Notes:         body.Notes,
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Create(&address).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save address"})
+		return
+	}
+
+	if body.IsDefault || count == 0 {
+		if err := setDefaultAddress(tx, address.UserID, address.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set default address"})
+			return
+		}
+		address.IsDefault = true
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, address)
+}
+
+func UpdateAddress(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	addressID := c.Param("id")
+
+	var body struct {
+		Label         *string `json:"label" binding:"omitempty,min=1,max=50"`
+		RecipientName *string `json:"recipientName" binding:"omitempty,min=1,max=100"`
+		Phone         *string `json:"phone"`
+		Line1         *string `json:"line1" binding:"omitempty,min=1,max=255"`
+		Line2         *string `json:"line2" binding:"omitempty,max=255"`
+		City          *string `json:"city" binding:"omitempty,min=1,max=100"`
+		Notes         *string `json:"notes" binding:"omitempty,max=255"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var address models.Address
+	if err := db.DB.Where("id = ? AND user_id = ?", addressID, userID).First(&address).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if body.Label != nil {
+		updates["label"] = *body.Label
+	}
+	if body.RecipientName != nil {
+		updates["recipient_name"] = *body.RecipientName
+	}
+	if body.Phone != nil {
+		phone, err := utils.NormalizePhone(*body.Phone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["phone"] = phone
+	}
+	if body.Line1 != nil {
+		updates["line1"] = *body.Line1
+	}
+	if body.Line2 != nil {
+		updates["line2"] = *body.Line2
+	}
+	if body.City != nil {
+		updates["city"] = *body.City
+	}
+	if body.Notes != nil {
+		updates["notes"] = *body.Notes
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+
+	if err := db.DB.Model(&address).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update address"})
+		return
+	}
+
+	c.JSON(http.StatusOK, address)
+}
+
+func SetDefaultAddress(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	addressID := c.Param("id")
+
+	var address models.Address
+	if err := db.DB.Where("id = ? AND user_id = ?", addressID, userID).First(&address).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return
+	}
+
+	tx := db.DB.Begin()
+	if err := setDefaultAddress(tx, address.UserID, address.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set default address"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Default address updated successfully"})
+}
+
+// DeleteAddress removes a saved address. If it was the default, the most
+// recently added remaining address becomes the default.
+func DeleteAddress(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	addressID := c.Param("id")
+
+	var address models.Address
+	if err := db.DB.Where("id = ? AND user_id = ?", addressID, userID).First(&address).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Delete(&address).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete address"})
+		return
+	}
+
+	if address.IsDefault {
+		var next models.Address
+		if err := tx.Where("user_id = ?", address.UserID).Order("created_at DESC").First(&next).Error; err == nil {
+			if err := setDefaultAddress(tx, next.UserID, next.ID); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set default address"})
+				return
+			}
+		}
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+}
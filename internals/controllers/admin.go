@@ -2,17 +2,29 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// lowStockThreshold is the quantity at or below which inventory is flagged.
+const lowStockThreshold = 20
+
 type RestockRequest struct {
-	BranchID  string `json:"branchId" binding:"required"`
-	ProductID string `json:"productId" binding:"required"`
-	Quantity  int    `json:"quantity" binding:"required,min=1"`
+	BranchID    string   `json:"branchId" binding:"required"`
+	ProductID   string   `json:"productId"` // Enough on its own for single-variant products
+	VariantID   string   `json:"variantId"`
+	SKU         string   `json:"sku"`
+	Quantity    int      `json:"quantity" binding:"required,min=1"`
+	UnitCost    *float64 `json:"unitCost" binding:"omitempty,min=0"`
+	BatchNumber string   `json:"batchNumber"`
+	ExpiryDate  string   `json:"expiryDate"` // YYYY-MM-DD
 }
 
 func RestockBranch(c *gin.Context) {
@@ -28,47 +40,44 @@ func RestockBranch(c *gin.Context) {
 		return
 	}
 
-	tx := db.DB.Begin()
+	if !canAccessBranch(c, req.BranchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this branch"})
+		return
+	}
 
-	// Get current inventory
-	var inventory models.BranchInventory
-	if err := tx.Where("branch_id = ? AND product_id = ?", req.BranchID, req.ProductID).First(&inventory).Error; err != nil {
-		// Create new inventory record if doesn't exist
-		inventory = models.BranchInventory{
-			BranchID:  req.BranchID,
-			ProductID: req.ProductID,
-			Quantity:  0,
-		}
-		if err := tx.Create(&inventory).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory record"})
-			return
-		}
+	expiryDate, err := parseOptionalDate(req.ExpiryDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiryDate, use YYYY-MM-DD"})
+		return
 	}
 
-	previousQuantity := inventory.Quantity
-	newQuantity := previousQuantity + req.Quantity
+	if req.ProductID == "" && req.VariantID == "" && req.SKU == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One of variantId, sku or productId is required"})
+		return
+	}
 
-	// Update inventory
-	if err := tx.Model(&inventory).Update("quantity", newQuantity).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+	variant, err := resolveVariant(db.DB, req.ProductID, req.VariantID, req.SKU)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Create restock log
+	tx := db.DB.Begin()
+
 	restockLog := models.RestockLog{
-		BranchID:         req.BranchID,
-		ProductID:        req.ProductID,
-		QuantityAdded:    req.Quantity,
-		PreviousQuantity: previousQuantity,
-		NewQuantity:      newQuantity,
-		RestockedBy:      userID.(string),
+		BranchID:      req.BranchID,
+		ProductID:     variant.ProductID,
+		VariantID:     variant.ID,
+		QuantityAdded: req.Quantity,
+		UnitCost:      req.UnitCost,
+		BatchNumber:   req.BatchNumber,
+		ExpiryDate:    expiryDate,
+		RestockedBy:   userID.(string),
 	}
 
-	if err := tx.Create(&restockLog).Error; err != nil {
+	if err := applyRestock(tx, &restockLog); err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create restock log"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -78,24 +87,108 @@ func RestockBranch(c *gin.Context) {
 		"message": "Branch restocked successfully",
 		"updatedInventory": gin.H{
 			"branchId":    req.BranchID,
-			"productId":   req.ProductID,
-			"previousQty": previousQuantity,
+			"productId":   variant.ProductID,
+			"variantId":   variant.ID,
+			"previousQty": restockLog.PreviousQuantity,
 			"addedQty":    req.Quantity,
-			"newQty":      newQuantity,
+			"newQty":      restockLog.NewQuantity,
+			"averageCost": restockLog.AverageCost,
 		},
 	})
 }
 
+// applyRestock adds restockLog.QuantityAdded to the branch's stock of the
+// variant inside tx, creating the inventory row if needed, and records the
+// log with the before/after quantities filled in. When the log carries a
+// UnitCost the branch's weighted-average cost is recalculated. Each restock
+// opens a new InventoryBatch carrying the log's batch number and expiry date.
+func applyRestock(tx *gorm.DB, restockLog *models.RestockLog) error {
+	// Get current inventory
+	var inventory models.BranchInventory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("branch_id = ? AND variant_id = ?", restockLog.BranchID, restockLog.VariantID).
+		First(&inventory).Error; err != nil {
+		// Create new inventory record if doesn't exist
+		inventory = models.BranchInventory{
+			BranchID:  restockLog.BranchID,
+			ProductID: restockLog.ProductID,
+			VariantID: restockLog.VariantID,
+			Quantity:  0,
+		}
+		if err := tx.Create(&inventory).Error; err != nil {
+			return errors.New("Failed to create inventory record")
+		}
+	}
+
+	restockLog.PreviousQuantity = inventory.Quantity
+	restockLog.NewQuantity = inventory.Quantity + restockLog.QuantityAdded
+	restockLog.AverageCost = inventory.AverageCost
+
+	if restockLog.UnitCost != nil {
+		restockLog.AverageCost = weightedAverageCost(inventory.Quantity, inventory.AverageCost, restockLog.QuantityAdded, *restockLog.UnitCost)
+	}
+
+	// Update inventory
+	if err := tx.Model(&inventory).Updates(map[string]interface{}{
+		"quantity":       restockLog.NewQuantity,
+		"average_cost":   restockLog.AverageCost,
+		"last_restocked": time.Now(),
+	}).Error; err != nil {
+		return errors.New("Failed to update inventory")
+	}
+
+	// Create restock log
+	if err := tx.Create(restockLog).Error; err != nil {
+		return errors.New("Failed to create restock log")
+	}
+
+	batch := models.InventoryBatch{
+		BranchID:          restockLog.BranchID,
+		ProductID:         restockLog.ProductID,
+		VariantID:         restockLog.VariantID,
+		BatchNumber:       restockLog.BatchNumber,
+		ExpiryDate:        restockLog.ExpiryDate,
+		QuantityReceived:  restockLog.QuantityAdded,
+		QuantityRemaining: restockLog.QuantityAdded,
+		UnitCost:          restockLog.UnitCost,
+		RestockLogID:      restockLog.ID,
+		Status:            "active",
+	}
+
+	if err := tx.Create(&batch).Error; err != nil {
+		return errors.New("Failed to create inventory batch")
+	}
+
+	return nil
+}
+
+// weightedAverageCost blends incoming stock at unitCost into the existing
+// average. Negative or empty stock carries no cost weight, and neither does
+// stock with no recorded cost, which predates cost tracking.
+func weightedAverageCost(onHand int, averageCost float64, added int, unitCost float64) float64 {
+	if onHand <= 0 || averageCost == 0 {
+		return unitCost
+	}
+	total := onHand + added
+	return (float64(onHand)*averageCost + float64(added)*unitCost) / float64(total)
+}
+
 func GetSalesReports(c *gin.Context) {
 	startDate := c.Query("startDate")
 	endDate := c.Query("endDate")
 	branchID := c.Query("branchId")
 	productID := c.Query("productId")
 
+	if branchID != "" && !canAccessBranch(c, branchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this branch"})
+		return
+	}
+
 	query := db.DB.Model(&models.Order{}).
 		Preload("Branch").
 		Preload("OrderItems.Product").
 		Where("payment_status = ?", "completed")
+	query = scopeToBranches(c, query, "branch_id")
 
 	// Apply filters
 	if startDate != "" {
@@ -114,11 +207,16 @@ func GetSalesReports(c *gin.Context) {
 		return
 	}
 
-	// Calculate sales by brand
-	salesByBrand := map[string]gin.H{
-		"Coke":   {"units": 0, "revenue": 0.0},
-		"Fanta":  {"units": 0, "revenue": 0.0},
-		"Sprite": {"units": 0, "revenue": 0.0},
+	// Calculate sales by brand, listing every brand even if it sold nothing
+	var brands []models.Brand
+	if err := db.DB.Unscoped().Order("name").Find(&brands).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch brands"})
+		return
+	}
+
+	salesByBrand := map[string]gin.H{}
+	for _, brand := range brands {
+		salesByBrand[brand.Name] = gin.H{"units": 0, "revenue": 0.0}
 	}
 
 	// Calculate sales by branch
@@ -134,10 +232,13 @@ func GetSalesReports(c *gin.Context) {
 
 		// Sales by brand
 		for _, item := range order.OrderItems {
-			if brandData, exists := salesByBrand[item.ProductBrand]; exists {
-				brandData["units"] = brandData["units"].(int) + item.Quantity
-				brandData["revenue"] = brandData["revenue"].(float64) + item.Subtotal
+			brandData, exists := salesByBrand[item.ProductBrand]
+			if !exists {
+				brandData = gin.H{"units": 0, "revenue": 0.0}
+				salesByBrand[item.ProductBrand] = brandData
 			}
+			brandData["units"] = brandData["units"].(int) + item.Quantity
+			brandData["revenue"] = brandData["revenue"].(float64) + item.Subtotal
 		}
 	}
 
@@ -159,6 +260,11 @@ func GetBranchReport(c *gin.Context) {
 	startDate := c.Query("startDate")
 	endDate := c.Query("endDate")
 
+	if !canAccessBranch(c, branchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this branch"})
+		return
+	}
+
 	var branch models.Branch
 	if err := db.DB.First(&branch, "id = ?", branchID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
@@ -203,12 +309,144 @@ func GetBranchReport(c *gin.Context) {
 	})
 }
 
+// GetMarginReport breaks completed sales down into revenue, cost of goods
+// sold and gross margin by brand, product, variant and branch.
+func GetMarginReport(c *gin.Context) {
+	startDate := c.Query("startDate")
+	endDate := c.Query("endDate")
+	branchID := c.Query("branchId")
+	productID := c.Query("productId")
+
+	if branchID != "" && !canAccessBranch(c, branchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this branch"})
+		return
+	}
+
+	query := db.DB.Model(&models.Order{}).
+		Preload("Branch").
+		Preload("OrderItems.Product").
+		Preload("OrderItems.Variant").
+		Where("payment_status = ?", "completed")
+	query = scopeToBranches(c, query, "branch_id")
+
+	if startDate != "" {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if endDate != "" {
+		query = query.Where("created_at <= ?", endDate)
+	}
+	if branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+
+	var orders []models.Order
+	if err := query.Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sales data"})
+		return
+	}
+
+	type marginTotals struct {
+		Name        string
+		Units       int
+		Revenue     float64
+		CostOfGoods float64
+	}
+
+	byBrand := map[string]*marginTotals{}
+	byProduct := map[string]*marginTotals{}
+	byVariant := map[string]*marginTotals{}
+	byBranch := map[string]*marginTotals{}
+	total := &marginTotals{}
+	uncostedItems := 0
+
+	add := func(group map[string]*marginTotals, key, name string, item models.OrderItem) {
+		totals, ok := group[key]
+		if !ok {
+			totals = &marginTotals{Name: name}
+			group[key] = totals
+		}
+		totals.Units += item.Quantity
+		totals.Revenue += item.Subtotal
+		totals.CostOfGoods += item.CostOfGoods
+	}
+
+	for _, order := range orders {
+		for _, item := range order.OrderItems {
+			if productID != "" && item.ProductID != productID {
+				continue
+			}
+			if item.UnitCost == 0 {
+				uncostedItems++
+			}
+
+			add(byBrand, item.ProductBrand, item.ProductBrand, item)
+			add(byProduct, item.ProductID, item.Product.Name, item)
+			add(byVariant, item.VariantID, item.Product.Name+" "+item.Variant.Name, item)
+			add(byBranch, order.BranchID, order.Branch.Name, item)
+
+			total.Units += item.Quantity
+			total.Revenue += item.Subtotal
+			total.CostOfGoods += item.CostOfGoods
+		}
+	}
+
+	summarise := func(t *marginTotals) gin.H {
+		grossProfit := t.Revenue - t.CostOfGoods
+		marginPercent := 0.0
+		if t.Revenue > 0 {
+			marginPercent = grossProfit / t.Revenue * 100
+		}
+		return gin.H{
+			"name":          t.Name,
+			"units":         t.Units,
+			"revenue":       t.Revenue,
+			"costOfGoods":   t.CostOfGoods,
+			"grossProfit":   grossProfit,
+			"marginPercent": marginPercent,
+		}
+	}
+
+	group := func(totals map[string]*marginTotals) map[string]gin.H {
+		result := map[string]gin.H{}
+		for key, t := range totals {
+			result[key] = summarise(t)
+		}
+		return result
+	}
+
+	overall := summarise(total)
+	delete(overall, "name")
+
+	c.JSON(http.StatusOK, gin.H{
+		"marginByBrand":   group(byBrand),
+		"marginByProduct": group(byProduct),
+		"marginByVariant": group(byVariant),
+		"marginByBranch":  group(byBranch),
+		"total":           overall,
+		// Items sold before cost tracking (or from stock with no recorded cost) count at zero cost
+		"uncostedItems": uncostedItems,
+		"filters": gin.H{
+			"startDate": startDate,
+			"endDate":   endDate,
+			"branchId":  branchID,
+			"productId": productID,
+		},
+	})
+}
+
 func GetInventory(c *gin.Context) {
 	branchID := c.Query("branchId")
 
+	if branchID != "" && !canAccessBranch(c, branchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this branch"})
+		return
+	}
+
 	query := db.DB.Model(&models.BranchInventory{}).
 		Preload("Branch").
-		Preload("Product")
+		Preload("Product").
+		Preload("Variant")
+	query = scopeToBranches(c, query, "branch_id")
 
 	if branchID != "" {
 		query = query.Where("branch_id = ?", branchID)
@@ -221,7 +459,6 @@ func GetInventory(c *gin.Context) {
 	}
 
 	// Add low stock alerts
-	lowStockThreshold := 20
 	lowStockItems := []gin.H{}
 
 	for _, inventory := range inventories {
@@ -231,6 +468,9 @@ func GetInventory(c *gin.Context) {
 				"branchName":   inventory.Branch.Name,
 				"productId":    inventory.ProductID,
 				"productName":  inventory.Product.Name,
+				"variantId":    inventory.VariantID,
+				"variantName":  inventory.Variant.Name,
+				"sku":          inventory.Variant.SKU,
 				"currentStock": inventory.Quantity,
 				"threshold":    lowStockThreshold,
 			})
@@ -244,19 +484,38 @@ func GetInventory(c *gin.Context) {
 	})
 }
 
+// restockLogResponse is a restock log as returned to clients, with the
+// requesting and restocking users cut down to their id and name.
+type restockLogResponse struct {
+	models.RestockLog
+	RequestedByUser gin.H
+	RestockedByUser gin.H
+}
+
 func GetRestockLogs(c *gin.Context) {
 	branchID := c.Query("branchId")
+	purchaseOrderID := c.Query("purchaseOrderId")
 	startDate := c.Query("startDate")
 	endDate := c.Query("endDate")
 
+	if branchID != "" && !canAccessBranch(c, branchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this branch"})
+		return
+	}
+
 	query := db.DB.Model(&models.RestockLog{}).
 		Preload("Branch").
 		Preload("Product").
+		Preload("RequestedByUser").
 		Preload("RestockedByUser")
+	query = scopeToBranches(c, query, "branch_id")
 
 	if branchID != "" {
 		query = query.Where("branch_id = ?", branchID)
 	}
+	if purchaseOrderID != "" {
+		query = query.Where("purchase_order_id = ?", purchaseOrderID)
+	}
 	if startDate != "" {
 		query = query.Where("created_at >= ?", startDate)
 	}
@@ -270,5 +529,14 @@ func GetRestockLogs(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, logs)
+	results := []restockLogResponse{}
+	for _, log := range logs {
+		results = append(results, restockLogResponse{
+			RestockLog:      log,
+			RequestedByUser: userRef(log.RequestedByUser),
+			RestockedByUser: userRef(&log.RestockedByUser),
+		})
+	}
+
+	c.JSON(http.StatusOK, results)
 }
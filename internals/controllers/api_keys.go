@@ -0,0 +1,158 @@
+// api keys controller
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func apiKeyResponse(key models.APIKey) gin.H {
+	scopes := []string{}
+	json.Unmarshal([]byte(key.Scopes), &scopes)
+
+	branchIDs := []string{}
+	for _, branch := range key.Branches {
+		branchIDs = append(branchIDs, branch.ID)
+	}
+
+	return gin.H{
+		"id":         key.ID,
+		"name":       key.Name,
+		"prefix":     key.Prefix,
+		"scopes":     scopes,
+		"branchIds":  branchIDs,
+		"expiresAt":  key.ExpiresAt,
+		"lastUsedAt": key.LastUsedAt,
+		"lastUsedIp": key.LastUsedIP,
+		"revokedAt":  key.RevokedAt,
+		"createdBy":  key.CreatedBy,
+		"createdAt":  key.CreatedAt,
+	}
+}
+
+// CreateAPIKey issues a key limited to the given permission scopes and,
+// optionally, to some branches. The key itself is only returned here.
+func CreateAPIKey(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Name      string   `json:"name" binding:"required"`
+		Scopes    []string `json:"scopes" binding:"required,min=1"`
+		BranchIDs []string `json:"branchIds"`
+		ExpiresAt string   `json:"expiresAt"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	for _, scope := range body.Scopes {
+		// Keys cannot manage users or other keys
+		if !permissions.IsValidPermission(scope) || scope == permissions.ManageUsers {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Scope cannot be granted to an API key: " + scope})
+			return
+		}
+	}
+
+	expiresAt, err := parseOptionalDate(body.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be YYYY-MM-DD"})
+		return
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"})
+		return
+	}
+
+	branches := []models.Branch{}
+	if len(body.BranchIDs) > 0 {
+		if err := db.DB.Where("id IN ?", body.BranchIDs).Find(&branches).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch branches"})
+			return
+		}
+		if len(branches) != len(body.BranchIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "One or more branches not found"})
+			return
+		}
+	}
+
+	secret, err := utils.GenerateRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+	rawKey := utils.APIKeyPrefix + secret
+
+	scopes, _ := json.Marshal(body.Scopes)
+	key := models.APIKey{
+		Name:      body.Name,
+		Prefix:    rawKey[:12],
+		KeyHash:   utils.HashToken(rawKey),
+		Scopes:    string(scopes),
+		Branches:  branches,
+		ExpiresAt: expiresAt,
+		CreatedBy: userID.(string),
+	}
+
+	if err := db.DB.Create(&key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	response := apiKeyResponse(key)
+	response["key"] = rawKey
+	response["message"] = "API key created. Copy it now; it will not be shown again."
+
+	c.JSON(http.StatusCreated, response)
+}
+
+func GetAPIKeys(c *gin.Context) {
+	query := db.DB.Model(&models.APIKey{}).Preload("Branches")
+	if c.Query("includeRevoked") != "true" {
+		query = query.Where("revoked_at IS NULL")
+	}
+
+	var keys []models.APIKey
+	if err := query.Order("created_at DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, key := range keys {
+		result = append(result, apiKeyResponse(key))
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RevokeAPIKey disables a key; requests using it are rejected immediately.
+func RevokeAPIKey(c *gin.Context) {
+	keyID := c.Param("id")
+
+	result := db.DB.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", keyID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found or already revoked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
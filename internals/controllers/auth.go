@@ -8,6 +8,7 @@ import (
 
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
 	"github.com/gin-gonic/gin"
 )
@@ -20,13 +21,30 @@ func setAuthCookie(c *gin.Context, token string) {
 		cookieName = defaultAuthCookieName
 	}
 	secure := os.Getenv("COOKIE_SECURE") == "true"
-	maxAge := int((24 * time.Hour).Seconds())
+	maxAge := int(utils.AccessTokenTTL.Seconds())
 
 	// Lax avoids CSRF on GET while allowing same-site POST in typical SPA flows
 	c.SetSameSite(http.SameSiteLaxMode)
 	c.SetCookie(cookieName, token, maxAge, "/", "", secure, true)
 }
 
+// sessionResponse is the body returned whenever a user signs in.
+func sessionResponse(message string, user models.User, token, refreshToken string) gin.H {
+	return gin.H{
+		"message": message,
+		"user": gin.H{
+			"id":    user.ID,
+			"name":  user.Name,
+			"email": user.Email,
+			"phone": user.Phone,
+			"role":  user.Role,
+		},
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(utils.AccessTokenTTL.Seconds()),
+	}
+}
+
 func Register(c *gin.Context) {
 	var body struct {
 		Name            string `json:"name" binding:"required"`
@@ -46,8 +64,14 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	phone, err := utils.NormalizePhone(body.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var existingUser models.User
-	if err := db.DB.Where("email = ? OR phone = ?", body.Email, body.Phone).First(&existingUser).Error; err == nil {
+	if err := db.DB.Where("email = ? OR phone = ?", body.Email, phone).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "Email or phone already exists"})
 		return
 	}
@@ -55,7 +79,7 @@ func Register(c *gin.Context) {
 	user := models.User{
 		Name:     body.Name,
 		Email:    body.Email,
-		Phone:    body.Phone,
+		Phone:    phone,
 		Password: utils.HashPassword(body.Password),
 		Role:     "customer",
 	}
@@ -65,20 +89,17 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	token := utils.GenerateJWT(user.ID, user.Role)
-	setAuthCookie(c, token)
+	if err := sendEmailVerification(user); err != nil {
+		utils.Logger.WithError(err).Error("Failed to send email verification")
+	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "User registered successfully",
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"phone": user.Phone,
-			"role":  user.Role,
-		},
-		"token": token,
-	})
+	token, refreshToken, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sessionResponse("User registered successfully", user, token, refreshToken))
 }
 
 func Login(c *gin.Context) {
@@ -92,40 +113,41 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if rejectThrottledLogin(c, body.Email) {
+		return
+	}
+
 	var user models.User
 	if err := db.DB.Where("email = ?", body.Email).First(&user).Error; err != nil {
+		recordLoginFailure(body.Email, c.ClientIP())
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	if !utils.CheckPassword(body.Password, user.Password) {
+		recordLoginFailure(body.Email, c.ClientIP())
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	token := utils.GenerateJWT(user.ID, user.Role)
-	setAuthCookie(c, token)
+	recordLoginSuccess(body.Email)
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"user": gin.H{
-			"id":    user.ID,
-			"name":  user.Name,
-			"email": user.Email,
-			"phone": user.Phone,
-			"role":  user.Role,
-		},
-		"token": token,
-	})
+	completeLogin(c, user, "Login successful")
 }
 
 func Logout(c *gin.Context) {
-	cookieName := os.Getenv("JWT_COOKIE_NAME")
-	if cookieName == "" {
-		cookieName = defaultAuthCookieName
+	// Revoke the session so the access token stops working immediately
+	if sessionID := c.GetString("sessionID"); sessionID != "" {
+		if err := db.DB.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end session"})
+			return
+		}
+		recordActivity(c, c.GetString("userID"), "logout", "")
 	}
-	
-	c.SetCookie(cookieName, "", -1, "/", "", false, true)
+
+	clearAuthCookies(c)
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
@@ -137,17 +159,28 @@ func GetCurrentUser(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := db.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+	if err := db.DB.Preload("Branches").Where("id = ?", userID).First(&user).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	branchIDs := []string{}
+	for _, branch := range user.Branches {
+		branchIDs = append(branchIDs, branch.ID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"id":    user.ID,
 		"name":  user.Name,
 		"email": user.Email,
 		"phone": user.Phone,
 		"role":  user.Role,
+		"emailVerified": user.EmailVerifiedAt != nil,
+		"phoneVerified": user.PhoneVerifiedAt != nil,
+		"twoFactorEnabled": user.TwoFactorEnabledAt != nil,
+		"preferredBranchId": user.PreferredBranchID,
+		"permissions": permissions.ForRole(user.Role),
+		"branchIds":   branchIDs,
 		"createdAt": user.CreatedAt,
 	})
 }
@@ -10,12 +10,12 @@ import (
 
 func CreateBranch(c *gin.Context) {
 	var body struct {
-		ID            string `json:"id" binding:"required"`
-		Name          string `json:"name" binding:"required,oneof=Nairobi Kisumu Mombasa Nakuru Eldoret"`
+		ID            string `json:"id" binding:"required,max=50"`
+		Name          string `json:"name" binding:"required,max=50"` // A permitted branch location
 		IsHeadquarter bool   `json:"isHeadquarter"`
-		Address       string `json:"address" binding:"required"`
-		Phone         string `json:"phone" binding:"required"`
-		Status        string `json:"status"`
+		Address       string `json:"address" binding:"required,max=255"`
+		Phone         string `json:"phone" binding:"required,max=20"`
+		Status        string `json:"status" binding:"omitempty,oneof=active inactive"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -23,6 +23,11 @@ func CreateBranch(c *gin.Context) {
 		return
 	}
 
+	if _, ok := findActiveLocation(body.Name); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Branches can only be opened at an active branch location"})
+		return
+	}
+
 	branch := models.Branch{
 		ID:            body.ID,
 		Name:          body.Name,
@@ -63,32 +68,99 @@ func GetBranch(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", versionETag(branch.Version))
 	c.JSON(http.StatusOK, branch)
 }
 
+// UpdateBranch changes the fields present in the request body and leaves
+// the rest alone, so isHeadquarter can be set to false. The client sends the
+// ETag it read as If-Match, and each change is audited. A branch's id is
+// referenced by its stock and orders and cannot be changed.
 func UpdateBranch(c *gin.Context) {
-	branchID := c.Param("id")
+	var body struct {
+		Name          *string `json:"name" binding:"omitempty,min=1,max=50"`
+		IsHeadquarter *bool   `json:"isHeadquarter"`
+		Address       *string `json:"address" binding:"omitempty,min=1,max=255"`
+		Phone         *string `json:"phone" binding:"omitempty,min=1,max=20"`
+		Status        *string `json:"status" binding:"omitempty,oneof=active inactive"`
+	}
+
+	if !bindPatch(c, &body, nil) {
+		return
+	}
 
 	var branch models.Branch
-	if err := db.DB.First(&branch, "id = ?", branchID).Error; err != nil {
+	if err := db.DB.First(&branch, "id = ?", c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
 		return
 	}
 
-	var updateData models.Branch
-	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+	if !checkIfMatch(c, branch.Version, "branch") {
 		return
 	}
 
-	if err := db.DB.Model(&branch).Updates(updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branch"})
-		return
+	updates := map[string]interface{}{}
+	changes := map[string]fieldChange{}
+
+	if body.Name != nil && *body.Name != branch.Name {
+		if _, ok := findActiveLocation(*body.Name); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Branches can only be opened at an active branch location"})
+			return
+		}
+		updates["name"] = *body.Name
+		changes["name"] = fieldChange{branch.Name, *body.Name}
+	}
+	if body.IsHeadquarter != nil && *body.IsHeadquarter != branch.IsHeadquarter {
+		updates["is_headquarter"] = *body.IsHeadquarter
+		changes["isHeadquarter"] = fieldChange{branch.IsHeadquarter, *body.IsHeadquarter}
+	}
+	if body.Address != nil && *body.Address != branch.Address {
+		updates["address"] = *body.Address
+		changes["address"] = fieldChange{branch.Address, *body.Address}
+	}
+	if body.Phone != nil && *body.Phone != branch.Phone {
+		updates["phone"] = *body.Phone
+		changes["phone"] = fieldChange{branch.Phone, *body.Phone}
+	}
+	if body.Status != nil && *body.Status != branch.Status {
+		updates["status"] = *body.Status
+		changes["status"] = fieldChange{branch.Status, *body.Status}
 	}
 
+	if len(updates) > 0 {
+		tx := db.DB.Begin()
+
+		saved, err := saveVersionedUpdate(tx, c, &models.Branch{}, "branch", branch.ID, branch.Version, updates, changes)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branch"})
+			return
+		}
+		if !saved {
+			tx.Rollback()
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "The branch has been changed by someone else; fetch it again and reapply your changes"})
+			return
+		}
+
+		tx.Commit()
+	}
+
+	db.DB.First(&branch, "id = ?", branch.ID)
+	c.Header("ETag", versionETag(branch.Version))
 	c.JSON(http.StatusOK, branch)
 }
 
+// GetBranchChanges lists the audited edits of a branch.
+func GetBranchChanges(c *gin.Context) {
+	var branch models.Branch
+	if err := db.DB.Unscoped().First(&branch, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+		return
+	}
+
+	getAuditLog(c, "branch", branch.ID)
+}
+
 func DeleteBranch(c *gin.Context) {
 	branchID := c.Param("id")
 
@@ -0,0 +1,144 @@
+// branch locations controller
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+)
+
+// findActiveLocation looks up a location where a branch may be opened.
+func findActiveLocation(name string) (models.BranchLocation, bool) {
+	var location models.BranchLocation
+	if err := db.DB.Where("name = ? AND status = ?", name, "active").First(&location).Error; err != nil {
+		return location, false
+	}
+	return location, true
+}
+
+func GetBranchLocations(c *gin.Context) {
+	var locations []models.BranchLocation
+	if err := db.DB.Order("name").Find(&locations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch branch locations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, locations)
+}
+
+func CreateBranchLocation(c *gin.Context) {
+	var body struct {
+		Name   string `json:"name" binding:"required,max=50"`
+		Region string `json:"region" binding:"max=50"`
+		Status string `json:"status" binding:"omitempty,oneof=active inactive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	location := models.BranchLocation{
+		Name:   strings.TrimSpace(body.Name),
+		Region: body.Region,
+		Status: "active",
+	}
+	if body.Status != "" {
+		location.Status = body.Status
+	}
+
+	var existing int64
+	db.DB.Unscoped().Model(&models.BranchLocation{}).Where("LOWER(name) = LOWER(?)", location.Name).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This branch location already exists"})
+		return
+	}
+
+	if err := db.DB.Create(&location).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create branch location"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, location)
+}
+
+// UpdateBranchLocation renames a location (its branches follow) or changes
+// its region or status. Inactive locations keep their branches but no new
+// branch can be opened there.
+func UpdateBranchLocation(c *gin.Context) {
+	var body struct {
+		Name   *string `json:"name" binding:"omitempty,min=1,max=50"`
+		Region *string `json:"region" binding:"omitempty,max=50"`
+		Status *string `json:"status" binding:"omitempty,oneof=active inactive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var location models.BranchLocation
+	if err := db.DB.First(&location, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch location not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if body.Name != nil {
+		name := strings.TrimSpace(*body.Name)
+		var existing int64
+		db.DB.Unscoped().Model(&models.BranchLocation{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, location.ID).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "This branch location already exists"})
+			return
+		}
+		updates["name"] = name
+	}
+	if body.Region != nil {
+		updates["region"] = *body.Region
+	}
+	if body.Status != nil {
+		updates["status"] = *body.Status
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+
+	// branches.name follows through ON UPDATE CASCADE
+	if err := db.DB.Model(&location).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branch location"})
+		return
+	}
+
+	db.DB.First(&location, "id = ?", location.ID)
+	c.JSON(http.StatusOK, location)
+}
+
+// DeleteBranchLocation removes a location no branch has ever used.
+func DeleteBranchLocation(c *gin.Context) {
+	var location models.BranchLocation
+	if err := db.DB.First(&location, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch location not found"})
+		return
+	}
+
+	var branchCount int64
+	db.DB.Unscoped().Model(&models.Branch{}).Where("name = ?", location.Name).Count(&branchCount)
+	if branchCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a location that has branches, make it inactive instead"})
+		return
+	}
+
+	// Hard delete so the name can be reused
+	if err := db.DB.Unscoped().Delete(&location).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete branch location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Branch location deleted successfully"})
+}
@@ -0,0 +1,181 @@
+// brands controller
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+)
+
+// findActiveBrand looks up a brand that new products may be assigned to.
+func findActiveBrand(name string) (models.Brand, bool) {
+	var brand models.Brand
+	if err := db.DB.Where("name = ? AND status = ?", name, "active").First(&brand).Error; err != nil {
+		return brand, false
+	}
+	return brand, true
+}
+
+// listBrands returns brands with the number of products each has.
+func listBrands(c *gin.Context, activeOnly bool) {
+	query := db.DB.Model(&models.Brand{}).
+		Select(`brands.id, brands.name, brands.description, brands.status,
+			(SELECT COUNT(*) FROM products p WHERE p.brand = brands.name AND p.deleted_at IS NULL) AS product_count`)
+	if activeOnly {
+		query = query.Where("brands.status = ?", "active")
+	}
+
+	var rows []struct {
+		ID           string
+		Name         string
+		Description  string
+		Status       string
+		ProductCount int64
+	}
+	if err := query.Order("brands.name").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch brands"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, row := range rows {
+		result = append(result, gin.H{
+			"id":           row.ID,
+			"name":         row.Name,
+			"description":  row.Description,
+			"status":       row.Status,
+			"productCount": row.ProductCount,
+		})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetBrands lists the active brands for the catalogue.
+func GetBrands(c *gin.Context) {
+	listBrands(c, true)
+}
+
+// GetAllBrands lists every brand, including inactive ones, for admins.
+func GetAllBrands(c *gin.Context) {
+	listBrands(c, false)
+}
+
+func CreateBrand(c *gin.Context) {
+	var body struct {
+		Name        string `json:"name" binding:"required,max=50"`
+		Description string `json:"description"`
+		Status      string `json:"status" binding:"omitempty,oneof=active inactive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	brand := models.Brand{
+		Name:        strings.TrimSpace(body.Name),
+		Description: body.Description,
+		Status:      "active",
+	}
+	if body.Status != "" {
+		brand.Status = body.Status
+	}
+
+	var existing int64
+	db.DB.Unscoped().Model(&models.Brand{}).Where("LOWER(name) = LOWER(?)", brand.Name).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A brand with this name already exists"})
+		return
+	}
+
+	if err := db.DB.Create(&brand).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create brand"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, brand)
+}
+
+// UpdateBrand renames a brand or changes its status. A rename carries over to
+// its products and past order items. Inactive brands stay on existing
+// products but cannot be given to new ones.
+func UpdateBrand(c *gin.Context) {
+	var body struct {
+		Name        *string `json:"name" binding:"omitempty,min=1,max=50"`
+		Description *string `json:"description"`
+		Status      *string `json:"status" binding:"omitempty,oneof=active inactive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var brand models.Brand
+	if err := db.DB.First(&brand, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if body.Name != nil {
+		name := strings.TrimSpace(*body.Name)
+		var existing int64
+		db.DB.Unscoped().Model(&models.Brand{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, brand.ID).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "A brand with this name already exists"})
+			return
+		}
+		updates["name"] = name
+	}
+	if body.Description != nil {
+		updates["description"] = *body.Description
+	}
+	if body.Status != nil {
+		updates["status"] = *body.Status
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+
+	// products.brand and order_items.product_brand follow through ON UPDATE CASCADE
+	if err := db.DB.Model(&brand).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update brand"})
+		return
+	}
+
+	db.DB.First(&brand, "id = ?", brand.ID)
+	c.JSON(http.StatusOK, brand)
+}
+
+// DeleteBrand removes a brand that has never been used. Brands with products
+// or sales should be made inactive instead.
+func DeleteBrand(c *gin.Context) {
+	var brand models.Brand
+	if err := db.DB.First(&brand, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Brand not found"})
+		return
+	}
+
+	var productCount, itemCount int64
+	db.DB.Unscoped().Model(&models.Product{}).Where("brand = ?", brand.Name).Count(&productCount)
+	db.DB.Unscoped().Model(&models.OrderItem{}).Where("product_brand = ?", brand.Name).Count(&itemCount)
+	if productCount > 0 || itemCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a brand with products or sales, make it inactive instead"})
+		return
+	}
+
+	// Hard delete so the name can be reused
+	if err := db.DB.Unscoped().Delete(&brand).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete brand"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Brand deleted successfully"})
+}
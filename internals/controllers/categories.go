@@ -0,0 +1,243 @@
+// categories controller
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// categoryTreeSQL selects the ids of the category matching ? (by id or slug)
+// and all of its descendants.
+const categoryTreeSQL = `WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE (id::text = ? OR slug = ?) AND deleted_at IS NULL
+		UNION ALL
+		SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id WHERE c.deleted_at IS NULL
+	) SELECT id FROM tree`
+
+// isCategoryDescendant reports whether candidateID is categoryID or sits
+// somewhere below it, which would make it an invalid new parent.
+func isCategoryDescendant(categoryID, candidateID string) bool {
+	var count int64
+	db.DB.Raw("SELECT COUNT(*) FROM ("+categoryTreeSQL+") t WHERE id = ?", categoryID, categoryID, candidateID).Scan(&count)
+	return count > 0
+}
+
+// GetCategories returns the category tree. Each category's productCount
+// includes the products in its subcategories.
+func GetCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := db.DB.Order("sort_order, name").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		return
+	}
+
+	var counts []struct {
+		CategoryID string
+		Count      int64
+	}
+	db.DB.Model(&models.Product{}).
+		Select("category_id, COUNT(*) AS count").
+		Where("category_id IS NOT NULL").
+		Group("category_id").
+		Scan(&counts)
+
+	direct := map[string]int64{}
+	for _, count := range counts {
+		direct[count.CategoryID] = count.Count
+	}
+
+	children := map[string][]models.Category{}
+	for _, category := range categories {
+		parentID := ""
+		if category.ParentID != nil {
+			parentID = *category.ParentID
+		}
+		children[parentID] = append(children[parentID], category)
+	}
+
+	var build func(parentID string) ([]gin.H, int64)
+	build = func(parentID string) ([]gin.H, int64) {
+		nodes := []gin.H{}
+		var total int64
+		for _, category := range children[parentID] {
+			subcategories, subtotal := build(category.ID)
+			count := direct[category.ID] + subtotal
+			total += count
+			nodes = append(nodes, gin.H{
+				"id":           category.ID,
+				"name":         category.Name,
+				"slug":         category.Slug,
+				"description":  category.Description,
+				"parentId":     category.ParentID,
+				"sortOrder":    category.SortOrder,
+				"productCount": count,
+				"children":     subcategories,
+			})
+		}
+		return nodes, total
+	}
+
+	tree, _ := build("")
+	c.JSON(http.StatusOK, tree)
+}
+
+func CreateCategory(c *gin.Context) {
+	var body struct {
+		Name        string  `json:"name" binding:"required,max=50"`
+		Description string  `json:"description"`
+		ParentID    *string `json:"parentId"`
+		SortOrder   int     `json:"sortOrder"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	category := models.Category{
+		Name:        body.Name,
+		Slug:        utils.Slugify(body.Name),
+		Description: body.Description,
+		SortOrder:   body.SortOrder,
+	}
+
+	if category.Slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category name must contain letters or digits"})
+		return
+	}
+
+	if body.ParentID != nil && *body.ParentID != "" {
+		var parent models.Category
+		if err := db.DB.First(&parent, "id = ?", *body.ParentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+			return
+		}
+		category.ParentID = &parent.ID
+	}
+
+	var existing int64
+	db.DB.Model(&models.Category{}).Where("slug = ?", category.Slug).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A category with this name already exists"})
+		return
+	}
+
+	if err := db.DB.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory renames or moves a category. Send "parentId": "" to make it
+// top-level; a category cannot be moved beneath itself.
+func UpdateCategory(c *gin.Context) {
+	var body struct {
+		Name        *string `json:"name" binding:"omitempty,max=50"`
+		Description *string `json:"description"`
+		ParentID    *string `json:"parentId"`
+		SortOrder   *int    `json:"sortOrder"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var category models.Category
+	if err := db.DB.First(&category, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+
+	if body.Name != nil {
+		slug := utils.Slugify(*body.Name)
+		if slug == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Category name must contain letters or digits"})
+			return
+		}
+
+		var existing int64
+		db.DB.Model(&models.Category{}).Where("slug = ? AND id <> ?", slug, category.ID).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "A category with this name already exists"})
+			return
+		}
+
+		updates["name"] = *body.Name
+		updates["slug"] = slug
+	}
+	if body.Description != nil {
+		updates["description"] = *body.Description
+	}
+	if body.SortOrder != nil {
+		updates["sort_order"] = *body.SortOrder
+	}
+	if body.ParentID != nil {
+		if *body.ParentID == "" {
+			updates["parent_id"] = nil
+		} else {
+			var parent models.Category
+			if err := db.DB.First(&parent, "id = ?", *body.ParentID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+				return
+			}
+			if isCategoryDescendant(category.ID, parent.ID) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A category cannot be moved beneath itself"})
+				return
+			}
+			updates["parent_id"] = parent.ID
+		}
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+
+	if err := db.DB.Model(&category).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+		return
+	}
+
+	db.DB.First(&category, "id = ?", category.ID)
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory deletes an empty category. Subcategories and products have
+// to be moved elsewhere first.
+func DeleteCategory(c *gin.Context) {
+	var category models.Category
+	if err := db.DB.First(&category, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var childCount int64
+	db.DB.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&childCount)
+	if childCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a category that has subcategories"})
+		return
+	}
+
+	var productCount int64
+	db.DB.Model(&models.Product{}).Where("category_id = ?", category.ID).Count(&productCount)
+	if productCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a category that still has products"})
+		return
+	}
+
+	if err := db.DB.Delete(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
@@ -0,0 +1,320 @@
+// inventory batches controller
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultNearExpiryDays = 30
+
+// parseOptionalDate parses a YYYY-MM-DD date, returning nil for an empty string.
+func parseOptionalDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+// consumeStock takes an order item's quantity out of a branch's stock of its variant,
+// picking from unexpired batches earliest-expiry first (FEFO) and, for
+// batches without an expiry date, oldest first (FIFO). Batches only track
+// part of the stock, so a quantity they do not cover is recorded against no
+// batch. The branch total is always decremented, going negative if the sale
+// exceeds recorded stock, because the customer has already paid by the time
+// stock is consumed.
+func consumeStock(tx *gorm.DB, branchID string, orderItem models.OrderItem) error {
+	var batches []models.InventoryBatch
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("branch_id = ? AND variant_id = ? AND status = ? AND quantity_remaining > 0", branchID, orderItem.VariantID, "active").
+		Where("expiry_date IS NULL OR expiry_date >= ?", time.Now().Truncate(24*time.Hour)).
+		Order("expiry_date ASC NULLS LAST, created_at ASC").
+		Find(&batches).Error; err != nil {
+		return errors.New("Failed to fetch inventory batches")
+	}
+
+	remaining := orderItem.Quantity
+	for _, batch := range batches {
+		if remaining == 0 {
+			break
+		}
+
+		take := batch.QuantityRemaining
+		if take > remaining {
+			take = remaining
+		}
+
+		updates := map[string]interface{}{
+			"quantity_remaining": batch.QuantityRemaining - take,
+		}
+		if batch.QuantityRemaining == take {
+			updates["status"] = "depleted"
+		}
+
+		if err := tx.Model(&batch).Updates(updates).Error; err != nil {
+			return errors.New("Failed to update inventory batch")
+		}
+
+		allocation := models.OrderItemBatch{
+			OrderItemID: orderItem.ID,
+			BatchID:     batch.ID,
+			Quantity:    take,
+		}
+		if err := tx.Create(&allocation).Error; err != nil {
+			return errors.New("Failed to record batch allocation")
+		}
+
+		remaining -= take
+	}
+
+	var inventory models.BranchInventory
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("branch_id = ? AND variant_id = ?", branchID, orderItem.VariantID).
+		First(&inventory).Error
+	if err == gorm.ErrRecordNotFound {
+		// Sold without ever being stocked here; start the row at zero so the
+		// sale shows up as a shortfall instead of being lost
+		inventory = models.BranchInventory{
+			BranchID:  branchID,
+			ProductID: orderItem.ProductID,
+			VariantID: orderItem.VariantID,
+		}
+		err = tx.Create(&inventory).Error
+	}
+	if err != nil {
+		return errors.New("Failed to fetch branch inventory")
+	}
+
+	if inventory.Quantity < orderItem.Quantity {
+		utils.Logger.WithFields(map[string]interface{}{
+			"branch_id":  branchID,
+			"product_id": orderItem.ProductID,
+			"variant_id": orderItem.VariantID,
+			"in_stock":   inventory.Quantity,
+			"sold":       orderItem.Quantity,
+		}).Warn("Sale exceeds recorded branch stock")
+	}
+
+	if err := tx.Model(&inventory).Update("quantity", gorm.Expr("quantity - ?", orderItem.Quantity)).Error; err != nil {
+		return errors.New("Failed to update inventory")
+	}
+
+	return nil
+}
+
+func GetInventoryBatches(c *gin.Context) {
+	branchID := c.Query("branchId")
+	productID := c.Query("productId")
+	variantID := c.Query("variantId")
+	status := c.Query("status")
+
+	query := db.DB.Model(&models.InventoryBatch{}).
+		Preload("Branch").
+		Preload("Product").
+		Preload("Variant")
+	query = scopeToBranches(c, query, "branch_id")
+
+	if branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+	if productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if variantID != "" {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var batches []models.InventoryBatch
+	if err := query.Order("expiry_date ASC NULLS LAST, created_at ASC").Find(&batches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory batches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, batches)
+}
+
+// GetNearExpiryReport lists batches with stock left that expire within the
+// given number of days (default 30), grouped by branch. Already expired
+// batches are included and flagged.
+func GetNearExpiryReport(c *gin.Context) {
+	branchID := c.Query("branchId")
+
+	days := defaultNearExpiryDays
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a non-negative integer"})
+			return
+		}
+		days = parsed
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	cutoff := today.AddDate(0, 0, days)
+
+	query := db.DB.Model(&models.InventoryBatch{}).
+		Preload("Branch").
+		Preload("Product").
+		Preload("Variant").
+		Where("status = ? AND quantity_remaining > 0", "active").
+		Where("expiry_date IS NOT NULL AND expiry_date <= ?", cutoff)
+	query = scopeToBranches(c, query, "branch_id")
+
+	if branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+
+	var batches []models.InventoryBatch
+	if err := query.Order("expiry_date ASC").Find(&batches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch near-expiry batches"})
+		return
+	}
+
+	byBranch := map[string][]gin.H{}
+	for _, batch := range batches {
+		byBranch[batch.Branch.Name] = append(byBranch[batch.Branch.Name], gin.H{
+			"batchId":           batch.ID,
+			"batchNumber":       batch.BatchNumber,
+			"branchId":          batch.BranchID,
+			"productId":         batch.ProductID,
+			"productName":       batch.Product.Name,
+			"variantId":         batch.VariantID,
+			"variantName":       batch.Variant.Name,
+			"sku":               batch.Variant.SKU,
+			"quantityRemaining": batch.QuantityRemaining,
+			"expiryDate":        batch.ExpiryDate.Format("2006-01-02"),
+			"daysToExpiry":      int(batch.ExpiryDate.Sub(today).Hours() / 24),
+			"expired":           batch.ExpiryDate.Before(today),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"nearExpiryByBranch": byBranch,
+		"days":               days,
+		"totalBatches":       len(batches),
+	})
+}
+
+// WriteOffExpiredBatches removes expired stock from branch inventory and
+// records each write-off in the restock log history.
+func WriteOffExpiredBatches(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		BranchID string   `json:"branchId"`
+		BatchIDs []string `json:"batchIds"`
+	}
+
+	// An empty body writes off every expired batch
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ? AND quantity_remaining > 0", "active").
+		Where("expiry_date IS NOT NULL AND expiry_date < ?", time.Now().Truncate(24*time.Hour))
+	query = scopeToBranches(c, query, "branch_id")
+
+	if body.BranchID != "" {
+		query = query.Where("branch_id = ?", body.BranchID)
+	}
+	if len(body.BatchIDs) > 0 {
+		query = query.Where("id IN ?", body.BatchIDs)
+	}
+
+	var batches []models.InventoryBatch
+	if err := query.Find(&batches).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch expired batches"})
+		return
+	}
+
+	writtenOff := []gin.H{}
+	for _, batch := range batches {
+		var inventory models.BranchInventory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("branch_id = ? AND variant_id = ?", batch.BranchID, batch.VariantID).
+			First(&inventory).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
+			return
+		}
+
+		newQuantity := inventory.Quantity - batch.QuantityRemaining
+		if err := tx.Model(&inventory).Update("quantity", newQuantity).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+			return
+		}
+
+		restockLog := models.RestockLog{
+			BranchID:         batch.BranchID,
+			ProductID:        batch.ProductID,
+			VariantID:        batch.VariantID,
+			MovementType:     "write_off",
+			QuantityAdded:    -batch.QuantityRemaining,
+			PreviousQuantity: inventory.Quantity,
+			NewQuantity:      newQuantity,
+			UnitCost:         batch.UnitCost,
+			AverageCost:      inventory.AverageCost,
+			BatchNumber:      batch.BatchNumber,
+			ExpiryDate:       batch.ExpiryDate,
+			RestockedBy:      userID.(string),
+		}
+		if err := tx.Create(&restockLog).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create restock log"})
+			return
+		}
+
+		if err := tx.Model(&batch).Updates(map[string]interface{}{
+			"quantity_remaining": 0,
+			"status":             "written_off",
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory batch"})
+			return
+		}
+
+		writtenOff = append(writtenOff, gin.H{
+			"batchId":      batch.ID,
+			"batchNumber":  batch.BatchNumber,
+			"branchId":     batch.BranchID,
+			"productId":    batch.ProductID,
+			"variantId":    batch.VariantID,
+			"quantity":     -restockLog.QuantityAdded,
+			"restockLogId": restockLog.ID,
+		})
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Expired batches written off successfully",
+		"writtenOff": writtenOff,
+	})
+}
@@ -0,0 +1,229 @@
+// login throttling controller
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// Failures older than this no longer count towards delays or lockouts
+	loginFailureWindow = 15 * time.Minute
+	loginLockDuration  = 15 * time.Minute
+	// After this many failures each further attempt must wait 1s, 2s, 4s, ...
+	loginDelayAfter = 3
+	maxLoginDelay   = time.Minute
+	accountLockAt   = 10
+	ipLockAt        = 50
+)
+
+func loginAccountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginRetryAfter returns how long the caller must wait before another login
+// attempt, based on the throttles for this account and client IP.
+func loginRetryAfter(email, ip string) time.Duration {
+	var throttles []models.LoginThrottle
+	db.DB.Where("(scope = ? AND key = ?) OR (scope = ? AND key = ?)", "account", loginAccountKey(email), "ip", ip).
+		Find(&throttles)
+
+	now := time.Now()
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+			if d := throttle.LockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+			continue
+		}
+
+		if throttle.Scope != "account" || now.Sub(throttle.LastFailedAt) > loginFailureWindow {
+			continue
+		}
+		if throttle.FailedCount >= loginDelayAfter {
+			delay := time.Duration(math.Pow(2, float64(throttle.FailedCount-loginDelayAfter))) * time.Second
+			if delay > maxLoginDelay {
+				delay = maxLoginDelay
+			}
+			if d := throttle.LastFailedAt.Add(delay).Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+
+	return wait
+}
+
+// recordThrottledFailure counts a failed login against scope/key and reports
+// whether this failure started a lockout.
+func recordThrottledFailure(scope, key string, lockAt int) bool {
+	now := time.Now()
+	tx := db.DB.Begin()
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}, {Name: "key"}},
+		DoNothing: true,
+	}).Create(&models.LoginThrottle{Scope: scope, Key: key, LastFailedAt: now}).Error; err != nil {
+		tx.Rollback()
+		return false
+	}
+
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND key = ?", scope, key).
+		First(&throttle).Error; err != nil {
+		tx.Rollback()
+		return false
+	}
+
+	count := throttle.FailedCount
+	if now.Sub(throttle.LastFailedAt) > loginFailureWindow {
+		count = 0
+	}
+	count++
+
+	updates := map[string]interface{}{
+		"failed_count":   count,
+		"last_failed_at": now,
+	}
+
+	locked := false
+	alreadyLocked := throttle.LockedUntil != nil && throttle.LockedUntil.After(now)
+	if count >= lockAt && !alreadyLocked {
+		updates["locked_until"] = now.Add(loginLockDuration)
+		locked = true
+	}
+
+	if err := tx.Model(&throttle).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return false
+	}
+
+	tx.Commit()
+	return locked
+}
+
+// recordLoginFailure counts a failed password login against both the account
+// and the client IP, and tells the account owner if it has just been locked.
+func recordLoginFailure(email, ip string) {
+	if recordThrottledFailure("ip", ip, ipLockAt) {
+		utils.Logger.WithField("ip", ip).Warn("Login locked for client IP after repeated failures")
+	}
+
+	if !recordThrottledFailure("account", loginAccountKey(email), accountLockAt) {
+		return
+	}
+
+	utils.Logger.WithFields(map[string]interface{}{
+		"email": email,
+		"ip":    ip,
+	}).Warn("Account login locked after repeated failures")
+
+	var user models.User
+	if err := db.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return
+	}
+
+	if err := services.NewNotifier().Send(services.Notification{
+		Channel: "email",
+		To:      user.Email,
+		Subject: "Your account has been temporarily locked",
+		Body: fmt.Sprintf("Hi %s,\n\nWe locked sign-in to your account for %d minutes after %d failed password attempts (most recently from %s).\n\nIf this wasn't you, reset your password at %s/forgot-password.",
+			user.Name, int(loginLockDuration.Minutes()), accountLockAt, ip, appURL()),
+	}); err != nil {
+		utils.Logger.WithError(err).Error("Failed to send lockout notification")
+	}
+}
+
+// recordLoginSuccess clears the account's failure count. The IP count is left
+// alone so an attacker cannot reset it by signing in to their own account.
+func recordLoginSuccess(email string) {
+	db.DB.Model(&models.LoginThrottle{}).
+		Where("scope = ? AND key = ?", "account", loginAccountKey(email)).
+		Updates(map[string]interface{}{"failed_count": 0, "locked_until": nil})
+}
+
+// GetLoginLocks lists accounts and IPs with recent failures or an active
+// lockout. Pass lockedOnly=true to see only current lockouts.
+func GetLoginLocks(c *gin.Context) {
+	now := time.Now()
+
+	query := db.DB.Model(&models.LoginThrottle{})
+	if c.Query("lockedOnly") == "true" {
+		query = query.Where("locked_until > ?", now)
+	} else {
+		query = query.Where("locked_until > ? OR (failed_count > 0 AND last_failed_at > ?)", now, now.Add(-loginFailureWindow))
+	}
+	if scope := c.Query("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+
+	var throttles []models.LoginThrottle
+	if err := query.Order("last_failed_at DESC").Find(&throttles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch login locks"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, throttle := range throttles {
+		result = append(result, gin.H{
+			"id":           throttle.ID,
+			"scope":        throttle.Scope,
+			"key":          throttle.Key,
+			"failedCount":  throttle.FailedCount,
+			"lastFailedAt": throttle.LastFailedAt,
+			"lockedUntil":  throttle.LockedUntil,
+			"locked":       throttle.LockedUntil != nil && throttle.LockedUntil.After(now),
+		})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ClearLoginLock lifts a lockout and resets its failure count.
+func ClearLoginLock(c *gin.Context) {
+	lockID := c.Param("id")
+
+	result := db.DB.Model(&models.LoginThrottle{}).
+		Where("id = ?", lockID).
+		Updates(map[string]interface{}{"failed_count": 0, "locked_until": nil})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear login lock"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Login lock not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Login lock cleared"})
+}
+
+// rejectThrottledLogin responds with 429 and returns true if the caller must
+// wait before trying to log in again.
+func rejectThrottledLogin(c *gin.Context, email string) bool {
+	wait := loginRetryAfter(email, c.ClientIP())
+	if wait <= 0 {
+		return false
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":      "Too many failed login attempts, please try again later",
+		"retryAfter": seconds,
+	})
+	return true
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
 )
 
 func CreateOrder(c *gin.Context) {
@@ -17,17 +19,18 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 
+	// Prices, subtotals, the total and brands are taken from the catalogue;
+	// clients that still send them have those values ignored
 	var body struct {
 		BranchID   string `json:"branchId" binding:"required"`
 		Items      []struct {
-			ProductID    string  `json:"productId" binding:"required"`
-			ProductBrand string  `json:"productBrand" binding:"required"`
-			Quantity     int     `json:"quantity" binding:"required,min=1"`
-			Price        float64 `json:"price" binding:"required,min=0"`
-			Subtotal     float64 `json:"subtotal" binding:"required,min=0"`
+			ProductID string `json:"productId"` // Enough on its own for single-variant products
+			VariantID string `json:"variantId"`
+			SKU       string `json:"sku"`
+			Quantity  int    `json:"quantity" binding:"required,min=1"`
 		} `json:"items" binding:"required,min=1"`
-		TotalAmount float64 `json:"totalAmount" binding:"required,min=0"`
 		Phone       string `json:"phone" binding:"required"`
+		AddressID   string `json:"addressId"` // Saved delivery address; omit for pickup
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -35,34 +38,100 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 
-	tx := db.DB.Begin()
+	// Work out which variant each item is for
+	productIDs, variantIDs, skus := []string{}, []string{}, []string{}
+	for _, item := range body.Items {
+		productIDs = append(productIDs, item.ProductID)
+		variantIDs = append(variantIDs, item.VariantID)
+		skus = append(skus, item.SKU)
+	}
+
+	candidates, err := findVariantCandidates(db.DB.Preload("Product"), productIDs, variantIDs, skus)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product variants"})
+		return
+	}
+
+	variants := []models.ProductVariant{}
+	for _, item := range body.Items {
+		variant, err := pickVariant(candidates, item.ProductID, item.VariantID, item.SKU)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if variant.Status != "active" || variant.Product == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Product variant is no longer sold: " + variant.SKU})
+			return
+		}
+		variants = append(variants, variant)
+	}
+
+	totalAmount := 0.0
+	for i, item := range body.Items {
+		totalAmount += variants[i].Price * float64(item.Quantity)
+	}
 
 	// Create order
 	order := models.Order{
 		UserID:        userID.(string),
 		BranchID:      body.BranchID,
-		TotalAmount:   body.TotalAmount,
+		TotalAmount:   totalAmount,
 		PaymentStatus: "pending",
 		PaymentMethod: "mpesa",
 		OrderStatus:   "processing",
 	}
 
+	if body.AddressID != "" {
+		var address models.Address
+		if err := db.DB.Where("id = ? AND user_id = ?", body.AddressID, userID).First(&address).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Address not found"})
+			return
+		}
+		order.AddressID = &address.ID
+		order.DeliveryAddress = formatAddress(address)
+	}
+
+	tx := db.DB.Begin()
+
 	if err := tx.Create(&order).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
 		return
 	}
 
+	// Capture the branch's current average cost so margins reflect cost at sale time
+	variantIDs = []string{}
+	for _, variant := range variants {
+		variantIDs = append(variantIDs, variant.ID)
+	}
+
+	var inventories []models.BranchInventory
+	if err := tx.Where("branch_id = ? AND variant_id IN ?", body.BranchID, variantIDs).Find(&inventories).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch branch inventory"})
+		return
+	}
+
+	unitCosts := map[string]float64{}
+	for _, inventory := range inventories {
+		unitCosts[inventory.VariantID] = inventory.AverageCost
+	}
+
 	// Create order items
 	orderItems := []models.OrderItem{}
-	for _, item := range body.Items {
+	for i, item := range body.Items {
+		variant := variants[i]
+		unitCost := unitCosts[variant.ID]
 		orderItem := models.OrderItem{
 			OrderID:      order.ID,
-			ProductID:    item.ProductID,
-			ProductBrand: item.ProductBrand,
+			ProductID:    variant.ProductID,
+			VariantID:    variant.ID,
+			ProductBrand: variant.Product.Brand,
 			Quantity:     item.Quantity,
-			Price:        item.Price,
-			Subtotal:     item.Subtotal,
+			Price:        variant.Price,
+			Subtotal:     variant.Price * float64(item.Quantity),
+			UnitCost:     unitCost,
+			CostOfGoods:  unitCost * float64(item.Quantity),
 		}
 		orderItems = append(orderItems, orderItem)
 	}
@@ -77,7 +146,7 @@ func CreateOrder(c *gin.Context) {
 	payment := models.Payment{
 		OrderID: order.ID,
 		Phone:   body.Phone,
-		Amount:  body.TotalAmount,
+		Amount:  totalAmount,
 		Status:  "pending",
 	}
 
@@ -106,6 +175,7 @@ func GetUserOrders(c *gin.Context) {
 	if err := db.DB.Where("user_id = ?", userID).
 		Preload("Branch").
 		Preload("OrderItems.Product").
+		Preload("OrderItems.Variant").
 		Order("created_at DESC").
 		Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
@@ -128,6 +198,7 @@ func GetOrderById(c *gin.Context) {
 	if err := db.DB.Where("id = ? AND user_id = ?", orderID, userID).
 		Preload("Branch").
 		Preload("OrderItems.Product").
+		Preload("OrderItems.Variant").
 		First(&order).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
@@ -136,6 +207,50 @@ func GetOrderById(c *gin.Context) {
 	c.JSON(http.StatusOK, order)
 }
 
+// GetBranchOrders lists orders for staff, limited to the caller's branches.
+func GetBranchOrders(c *gin.Context) {
+	branchID := c.Query("branchId")
+	status := c.Query("status")
+	paymentStatus := c.Query("paymentStatus")
+	startDate := c.Query("startDate")
+	endDate := c.Query("endDate")
+
+	if branchID != "" && !canAccessBranch(c, branchID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this branch"})
+		return
+	}
+
+	query := db.DB.Model(&models.Order{}).
+		Preload("Branch").
+		Preload("OrderItems.Product").
+		Preload("OrderItems.Variant")
+	query = scopeToBranches(c, query, "branch_id")
+
+	if branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+	if status != "" {
+		query = query.Where("order_status = ?", status)
+	}
+	if paymentStatus != "" {
+		query = query.Where("payment_status = ?", paymentStatus)
+	}
+	if startDate != "" {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if endDate != "" {
+		query = query.Where("created_at <= ?", endDate)
+	}
+
+	var orders []models.Order
+	if err := query.Order("created_at DESC").Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
 func UpdateOrderStatus(c *gin.Context) {
 	orderID := c.Param("id")
 	
@@ -148,12 +263,34 @@ func UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
+	tx := db.DB.Begin()
+
+	// Locked so that a concurrent update or M-Pesa callback cannot also see
+	// the order as unpaid and take its items out of stock a second time
 	var order models.Order
-	if err := db.DB.Where("id = ?", orderID).First(&order).Error; err != nil {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
 
+	if !canAccessBranch(c, order.BranchID) {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+
+	alreadyPaid := order.PaymentStatus == "completed"
+	confirmingPayment := body.Status == "completed" && !alreadyPaid
+
+	// Completing an unpaid order records that it was paid some other way,
+	// which cashiers cannot vouch for
+	if confirmingPayment && !hasPermission(c, permissions.ConfirmPayments) {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Order has not been paid; only staff who can confirm payments may complete it", "permission": permissions.ConfirmPayments})
+		return
+	}
+
 	updates := map[string]interface{}{
 		"order_status": body.Status,
 	}
@@ -164,7 +301,33 @@ func UpdateOrderStatus(c *gin.Context) {
 		updates["payment_status"] = "completed"
 	}
 
-	if err := db.DB.Model(&order).Updates(updates).Error; err != nil {
+	if err := tx.Model(&order).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+		return
+	}
+
+	// Completing an unpaid order (e.g. a cash sale at the counter) is the sale,
+	// so its items leave branch stock just as they do on M-Pesa confirmation
+	if confirmingPayment {
+		// Any M-Pesa request still open for the order is settled too, so a
+		// late callback does not treat the order as newly paid
+		if err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status = ?", order.ID, "pending").
+			Update("status", "completed").Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment"})
+			return
+		}
+
+		if err := consumeOrderStock(tx, order.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branch stock"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
 		return
 	}
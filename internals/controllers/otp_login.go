@@ -0,0 +1,133 @@
+// phone OTP login controller
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	loginOTPTTL         = 5 * time.Minute
+	loginOTPResendDelay = time.Minute
+	maxLoginOTPsPerHour = 5
+)
+
+// findOTPLoginUser finds the customer who may log in with a code sent to
+// phone. Staff must log in with their password (and 2FA), and phone numbers
+// are not unique, so a number shared by several customers logs in no one
+// rather than whichever account happens to come first.
+func findOTPLoginUser(phone string) (models.User, bool) {
+	var users []models.User
+	if err := db.DB.Where("phone = ? AND role = ?", phone, permissions.RoleCustomer).
+		Limit(2).
+		Find(&users).Error; err != nil || len(users) != 1 {
+		return models.User{}, false
+	}
+	return users[0], true
+}
+
+// RequestLoginOTP texts a one-time login code to a registered phone number.
+// It responds the same way for unknown numbers, and when the number has
+// asked for too many codes, so it cannot be used to find customers.
+func RequestLoginOTP(c *gin.Context) {
+	var body struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	phone, err := utils.NormalizePhone(body.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"message":   "If this number is registered, a login code has been sent",
+		"phone":     phone,
+		"expiresIn": int(loginOTPTTL.Seconds()),
+	}
+
+	user, ok := findOTPLoginUser(phone)
+	if !ok {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var recent []models.UserToken
+	db.DB.Where("user_id = ? AND purpose = ? AND created_at > ?", user.ID, "login_otp", time.Now().Add(-time.Hour)).
+		Order("created_at DESC").
+		Find(&recent)
+
+	// A 429 would only ever be seen by registered numbers, so limited
+	// requests get the usual response and no code
+	if (len(recent) > 0 && time.Since(recent[0].CreatedAt) < loginOTPResendDelay) ||
+		len(recent) >= maxLoginOTPsPerHour {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	code, err := issueUserToken(user.ID, "login_otp", phone, loginOTPTTL, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create login code"})
+		return
+	}
+
+	message := fmt.Sprintf("Your Drinx login code is %s. It expires in 5 minutes. Do not share it.", code)
+	if err := services.NewSMSProvider().SendSMS(phone, message); err != nil {
+		utils.Logger.WithError(err).Error("Failed to send login code")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send login code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// VerifyLoginOTP exchanges a valid login code for the same session as Login.
+// A code is locked after maxCodeAttempts wrong guesses.
+func VerifyLoginOTP(c *gin.Context) {
+	var body struct {
+		Phone string `json:"phone" binding:"required"`
+		Code  string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	phone, err := utils.NormalizePhone(body.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := findOTPLoginUser(phone)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+
+	if _, err := redeemUserToken("login_otp", body.Code, user.ID); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+
+	// Receiving the code proves the customer holds this number
+	if user.PhoneVerifiedAt == nil {
+		db.DB.Model(&user).Update("phone_verified_at", time.Now())
+	}
+
+	completeLogin(c, user, "Login successful")
+}
@@ -0,0 +1,189 @@
+// partial update helpers
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// fieldChange is one field's old and new value in an audit record.
+type fieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// bindPatch decodes a partial update into body, a struct of pointer fields
+// that is the only set of fields the client may change. Fields the struct
+// does not list, such as ids and timestamps, are rejected rather than
+// silently ignored; readOnly explains why for the ones clients commonly try.
+func bindPatch(c *gin.Context, body interface{}, readOnly map[string]string) bool {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(body); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			field, _ = strconv.Unquote(field)
+			message := field + " cannot be changed"
+			if reason, ok := readOnly[field]; ok {
+				message += "; " + reason
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return false
+	}
+
+	if err := binding.Validator.ValidateStruct(body); err != nil {
+		var fieldErrors validator.ValidationErrors
+		if errors.As(err, &fieldErrors) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid value for " + jsonFieldName(body, fieldErrors[0].StructField())})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return false
+	}
+
+	return true
+}
+
+// jsonFieldName maps a struct field of body to the name clients send.
+func jsonFieldName(body interface{}, structField string) string {
+	t := reflect.TypeOf(body)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if field, ok := t.FieldByName(structField); ok {
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" {
+			return name
+		}
+	}
+	return structField
+}
+
+// versionETag formats a record's version as an HTTP entity tag.
+func versionETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// checkIfMatch compares the If-Match header with the record's current
+// version. PATCH requests must send it; the older PUT routes may leave it
+// out. A stale version means someone else changed the record since the
+// client read it, so the client must fetch it again rather than overwrite
+// their edit.
+func checkIfMatch(c *gin.Context, version int, resource string) bool {
+	c.Header("ETag", versionETag(version))
+
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if c.Request.Method == http.MethodPatch {
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the " + resource + "'s ETag is required"})
+			return false
+		}
+		return true
+	}
+	if header == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == versionETag(version) {
+			return true
+		}
+	}
+
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":   "The " + resource + " has been changed by someone else; fetch it again and reapply your changes",
+		"version": version,
+	})
+	return false
+}
+
+// saveVersionedUpdate applies updates to the record of model with the given
+// id, provided it is still at version, bumps the version and records the
+// changes in the audit log. It returns false when the record has moved on
+// to another version in the meantime.
+func saveVersionedUpdate(tx *gorm.DB, c *gin.Context, model interface{}, entityType, id string, version int, updates map[string]interface{}, changes map[string]fieldChange) (bool, error) {
+	updates["version"] = gorm.Expr("version + 1")
+
+	result := tx.Model(model).Where("id = ? AND version = ?", id, version).Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	userID, _ := c.Get("userID")
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Create(&models.AuditLog{
+		EntityType: entityType,
+		EntityID:   id,
+		Version:    version + 1,
+		Changes:    string(changesJSON),
+		ChangedBy:  userID.(string),
+		IPAddress:  c.ClientIP(),
+	}).Error
+}
+
+// getAuditLog lists the recorded edits of one record, newest first.
+func getAuditLog(c *gin.Context, entityType, id string) {
+	limit := pageLimit(c)
+	page := pageNumber(c)
+
+	query := db.DB.Model(&models.AuditLog{}).Where("entity_type = ? AND entity_id = ?", entityType, id)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count changes"})
+		return
+	}
+
+	var logs []models.AuditLog
+	if err := query.Preload("ChangedByUser").
+		Order("created_at DESC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch changes"})
+		return
+	}
+
+	results := []gin.H{}
+	for _, log := range logs {
+		results = append(results, gin.H{
+			"id":        log.ID,
+			"version":   log.Version,
+			"changes":   json.RawMessage(log.Changes),
+			"changedBy": userRef(&log.ChangedByUser),
+			"ipAddress": log.IPAddress,
+			"createdAt": log.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changes": results,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MpesaInitiateRequest struct {
@@ -215,6 +217,35 @@ func MpesaCallback(c *gin.Context) {
 		return
 	}
 
+	// Lock the order, then re-read the payment, so that concurrent callbacks
+	// and staff completing the order by hand are applied one at a time. The
+	// order is locked first, as UpdateOrderStatus does. Stock has already
+	// been taken if either an earlier callback or staff settled the order.
+	var lockedOrder models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lockedOrder, "id = ?", payment.OrderID).Error; err != nil {
+		tx.Rollback()
+		utils.Logger.WithFields(map[string]interface{}{
+			"checkout_request_id": checkoutRequestID,
+			"order_id":            payment.OrderID,
+			"error":               err.Error(),
+		}).Error("Failed to lock order")
+		// ALWAYS return 200 OK to M-Pesa
+		c.JSON(http.StatusOK, gin.H{"ResultCode": 0, "ResultDesc": "Accepted"})
+		return
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, "id = ?", payment.ID).Error; err != nil {
+		tx.Rollback()
+		utils.Logger.WithFields(map[string]interface{}{
+			"checkout_request_id": checkoutRequestID,
+			"order_id":            payment.OrderID,
+			"error":               err.Error(),
+		}).Error("Failed to lock payment")
+		// ALWAYS return 200 OK to M-Pesa
+		c.JSON(http.StatusOK, gin.H{"ResultCode": 0, "ResultDesc": "Accepted"})
+		return
+	}
+	alreadyCompleted := payment.Status == "completed" || lockedOrder.PaymentStatus == "completed"
+
 	// Update payment status
 	paymentStatus := "failed"
 	mpesaReceipt := ""
@@ -295,6 +326,23 @@ func MpesaCallback(c *gin.Context) {
 			return
 		}
 
+		// Take the sold items out of branch stock once; repeated callbacks for a
+		// payment that is already completed must not consume stock again.
+		// The customer has paid and M-Pesa will not call back again, so a
+		// stock failure is logged for staff to correct and never undoes the
+		// payment.
+		if !alreadyCompleted {
+			tx.SavePoint("consume_stock")
+			if err := consumeOrderStock(tx, payment.OrderID); err != nil {
+				tx.RollbackTo("consume_stock")
+				utils.Logger.WithFields(map[string]interface{}{
+					"checkout_request_id": checkoutRequestID,
+					"order_id":            payment.OrderID,
+					"error":               err.Error(),
+				}).Error("Paid order's stock was not consumed; branch inventory needs correcting")
+			}
+		}
+
 		utils.Logger.WithFields(map[string]interface{}{
 			"checkout_request_id": checkoutRequestID,
 			"merchant_request_id": stkCallback.MerchantRequestID,
@@ -344,6 +392,22 @@ func MpesaCallback(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ResultCode": 0, "ResultDesc": "Accepted"})
 }
 
+// consumeOrderStock takes all of a paid order's items out of its branch's
+// stock.
+func consumeOrderStock(tx *gorm.DB, orderID string) error {
+	var order models.Order
+	if err := tx.Preload("OrderItems").First(&order, "id = ?", orderID).Error; err != nil {
+		return err
+	}
+
+	for _, item := range order.OrderItems {
+		if err := consumeStock(tx, order.BranchID, item); err != nil {
+			return fmt.Errorf("variant %s: %w", item.VariantID, err)
+		}
+	}
+	return nil
+}
+
 func GetPaymentStatus(c *gin.Context) {
 	userID, exists := c.Get("userID")
 	if !exists {
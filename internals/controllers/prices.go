@@ -0,0 +1,434 @@
+// price history and scheduled price changes controller
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// priceSchedulerInterval is how often due scheduled price changes are applied.
+const priceSchedulerInterval = time.Minute
+
+// recordInitialPrice starts a new variant's price history.
+func recordInitialPrice(tx *gorm.DB, variant models.ProductVariant, changedBy string) error {
+	return tx.Create(&models.PriceChange{
+		VariantID:     variant.ID,
+		Price:         variant.Price,
+		OriginalPrice: variant.OriginalPrice,
+		Source:        "created",
+		ChangedBy:     &changedBy,
+		EffectiveAt:   time.Now(),
+	}).Error
+}
+
+// recordPriceChange adds a change from the variant's current prices to its
+// price history. Nothing is recorded when neither price differs.
+func recordPriceChange(tx *gorm.DB, variant models.ProductVariant, price, originalPrice float64, source string, changedBy, scheduledID *string) error {
+	if price == variant.Price && originalPrice == variant.OriginalPrice {
+		return nil
+	}
+	return tx.Create(&models.PriceChange{
+		VariantID:              variant.ID,
+		Price:                  price,
+		OriginalPrice:          originalPrice,
+		PreviousPrice:          &variant.Price,
+		PreviousOriginalPrice:  &variant.OriginalPrice,
+		Source:                 source,
+		ChangedBy:              changedBy,
+		ScheduledPriceChangeID: scheduledID,
+		EffectiveAt:            time.Now(),
+	}).Error
+}
+
+// userRef names the user behind a change without exposing their account.
+func userRef(user *models.User) gin.H {
+	if user == nil || user.ID == "" {
+		return nil
+	}
+	return gin.H{"id": user.ID, "name": user.Name}
+}
+
+func priceChangeSummary(change models.PriceChange) gin.H {
+	return gin.H{
+		"id":                     change.ID,
+		"price":                  change.Price,
+		"originalPrice":          change.OriginalPrice,
+		"previousPrice":          change.PreviousPrice,
+		"previousOriginalPrice":  change.PreviousOriginalPrice,
+		"source":                 change.Source,
+		"changedBy":              userRef(change.ChangedByUser),
+		"scheduledPriceChangeId": change.ScheduledPriceChangeID,
+		"effectiveAt":            change.EffectiveAt,
+	}
+}
+
+func scheduledPriceSummary(scheduled models.ScheduledPriceChange) gin.H {
+	summary := gin.H{
+		"id":            scheduled.ID,
+		"variantId":     scheduled.VariantID,
+		"price":         scheduled.Price,
+		"originalPrice": scheduled.OriginalPrice,
+		"effectiveAt":   scheduled.EffectiveAt,
+		"status":        scheduled.Status,
+		"note":          scheduled.Note,
+		"createdBy":     userRef(&scheduled.CreatedByUser),
+		"createdAt":     scheduled.CreatedAt,
+		"appliedAt":     scheduled.AppliedAt,
+		"cancelledBy":   scheduled.CancelledBy,
+		"cancelledAt":   scheduled.CancelledAt,
+	}
+	if scheduled.Variant.ID != "" {
+		summary["productId"] = scheduled.Variant.ProductID
+		summary["variantName"] = scheduled.Variant.Name
+		summary["sku"] = scheduled.Variant.SKU
+		summary["currentPrice"] = scheduled.Variant.Price
+	}
+	return summary
+}
+
+// parsePriceTime accepts an RFC 3339 timestamp or a date. A date means the
+// end of that day, so ?at=2025-03-31 gives the prices March closed on.
+func parsePriceTime(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), true
+	}
+	return time.Time{}, false
+}
+
+// GetPriceTimeline returns the price history of each of a product's variants,
+// oldest first, with any pending scheduled changes. Deleted variants are
+// included so that past sales can still be priced. With ?at= each variant
+// also reports the prices in effect at that time.
+func GetPriceTimeline(c *gin.Context) {
+	var at *time.Time
+	if value := c.Query("at"); value != "" {
+		t, ok := parsePriceTime(value)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be a date (YYYY-MM-DD) or an RFC 3339 timestamp"})
+			return
+		}
+		at = &t
+	}
+
+	var product models.Product
+	if err := db.DB.Unscoped().First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	var variants []models.ProductVariant
+	if err := db.DB.Unscoped().Where("product_id = ?", product.ID).Order("created_at ASC").Find(&variants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch variants"})
+		return
+	}
+
+	variantIDs := []string{}
+	for _, variant := range variants {
+		variantIDs = append(variantIDs, variant.ID)
+	}
+
+	var changes []models.PriceChange
+	if err := db.DB.Preload("ChangedByUser").
+		Where("variant_id IN ?", variantIDs).
+		Order("effective_at ASC, created_at ASC").
+		Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+		return
+	}
+
+	var scheduled []models.ScheduledPriceChange
+	if err := db.DB.Preload("CreatedByUser").
+		Where("variant_id IN ? AND status = ?", variantIDs, "pending").
+		Order("effective_at ASC").
+		Find(&scheduled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled price changes"})
+		return
+	}
+
+	timelines := []gin.H{}
+	for _, variant := range variants {
+		history := []gin.H{}
+		var priceAt gin.H
+		for _, change := range changes {
+			if change.VariantID != variant.ID {
+				continue
+			}
+			history = append(history, priceChangeSummary(change))
+			if at != nil && !change.EffectiveAt.After(*at) {
+				priceAt = gin.H{
+					"price":         change.Price,
+					"originalPrice": change.OriginalPrice,
+					"effectiveAt":   change.EffectiveAt,
+				}
+			}
+		}
+
+		upcoming := []gin.H{}
+		for _, change := range scheduled {
+			if change.VariantID == variant.ID {
+				upcoming = append(upcoming, scheduledPriceSummary(change))
+			}
+		}
+
+		timeline := gin.H{
+			"variantId":     variant.ID,
+			"name":          variant.Name,
+			"sku":           variant.SKU,
+			"status":        variant.Status,
+			"deleted":       variant.DeletedAt.Valid,
+			"price":         variant.Price,
+			"originalPrice": variant.OriginalPrice,
+			"history":       history,
+			"scheduled":     upcoming,
+		}
+		if at != nil {
+			// nil when the variant did not exist yet
+			timeline["priceAt"] = priceAt
+		}
+		timelines = append(timelines, timeline)
+	}
+
+	response := gin.H{
+		"productId": product.ID,
+		"name":      product.Name,
+		"variants":  timelines,
+	}
+	if at != nil {
+		response["at"] = at
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SchedulePriceChange sets a variant's price to change at a future time.
+func SchedulePriceChange(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Price         float64  `json:"price" binding:"required,min=0"`
+		OriginalPrice *float64 `json:"originalPrice" binding:"omitempty,min=0"`
+		EffectiveAt   string   `json:"effectiveAt" binding:"required"`
+		Note          string   `json:"note" binding:"max=255"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	effectiveAt, err := time.Parse(time.RFC3339, body.EffectiveAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effectiveAt must be an RFC 3339 timestamp, e.g. 2025-03-03T00:00:00+03:00"})
+		return
+	}
+	if !effectiveAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "effectiveAt must be in the future; change the variant's price directly instead"})
+		return
+	}
+
+	var variant models.ProductVariant
+	if err := db.DB.First(&variant, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	}
+
+	scheduled := models.ScheduledPriceChange{
+		VariantID:     variant.ID,
+		Price:         body.Price,
+		OriginalPrice: body.OriginalPrice,
+		EffectiveAt:   effectiveAt,
+		Status:        "pending",
+		Note:          strings.TrimSpace(body.Note),
+		CreatedBy:     userID.(string),
+	}
+
+	if err := db.DB.Create(&scheduled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule price change"})
+		return
+	}
+
+	db.DB.Preload("Variant").Preload("CreatedByUser").First(&scheduled, "id = ?", scheduled.ID)
+	c.JSON(http.StatusCreated, scheduledPriceSummary(scheduled))
+}
+
+// GetScheduledPriceChanges lists scheduled price changes, pending ones by
+// default, soonest first. Filter with status, productId and variantId.
+func GetScheduledPriceChanges(c *gin.Context) {
+	query := db.DB.Model(&models.ScheduledPriceChange{}).Preload("Variant").Preload("CreatedByUser")
+
+	if status := c.DefaultQuery("status", "pending"); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if variantID := c.Query("variantId"); variantID != "" {
+		query = query.Where("variant_id = ?", variantID)
+	}
+	if productID := c.Query("productId"); productID != "" {
+		query = query.Where("variant_id IN (SELECT id FROM product_variants WHERE product_id = ?)", productID)
+	}
+
+	var scheduled []models.ScheduledPriceChange
+	if err := query.Order("effective_at ASC").Find(&scheduled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled price changes"})
+		return
+	}
+
+	results := []gin.H{}
+	for _, change := range scheduled {
+		results = append(results, scheduledPriceSummary(change))
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// CancelScheduledPriceChange stops a pending price change from being applied.
+func CancelScheduledPriceChange(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	cancelledBy := userID.(string)
+	now := time.Now()
+
+	// Conditional on status so that a change the scheduler is applying right
+	// now is not reported as cancelled
+	result := db.DB.Model(&models.ScheduledPriceChange{}).
+		Where("id = ? AND status = ?", c.Param("id"), "pending").
+		Updates(map[string]interface{}{
+			"status":       "cancelled",
+			"cancelled_by": cancelledBy,
+			"cancelled_at": now,
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scheduled price change"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		var scheduled models.ScheduledPriceChange
+		if err := db.DB.First(&scheduled, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled price change not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending price changes can be cancelled, this one is " + scheduled.Status})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled price change cancelled"})
+}
+
+// applyScheduledPriceChange applies one due change, if it is still pending,
+// and reports whether it did. Rows are locked with SKIP LOCKED so several
+// servers can run the scheduler without applying a change twice.
+func applyScheduledPriceChange(id string) (bool, error) {
+	applied := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var scheduled models.ScheduledPriceChange
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND status = ?", id, "pending").
+			First(&scheduled).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		var variant models.ProductVariant
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ?", scheduled.VariantID).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Model(&scheduled).Updates(map[string]interface{}{
+				"status":       "cancelled",
+				"cancelled_at": now,
+			}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		originalPrice := variant.OriginalPrice
+		if scheduled.OriginalPrice != nil {
+			originalPrice = *scheduled.OriginalPrice
+		}
+
+		if err := recordPriceChange(tx, variant, scheduled.Price, originalPrice, "scheduled", &scheduled.CreatedBy, &scheduled.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&variant).Updates(map[string]interface{}{
+			"price":          scheduled.Price,
+			"original_price": originalPrice,
+		}).Error; err != nil {
+			return err
+		}
+		if err := refreshProductSummary(tx, variant.ProductID); err != nil {
+			return err
+		}
+
+		applied = true
+		return tx.Model(&scheduled).Updates(map[string]interface{}{
+			"status":     "applied",
+			"applied_at": now,
+		}).Error
+	})
+	return applied && err == nil, err
+}
+
+// ApplyDuePriceChanges applies every pending scheduled price change whose
+// time has come, oldest first, and returns how many were applied.
+func ApplyDuePriceChanges() int {
+	var due []models.ScheduledPriceChange
+	if err := db.DB.Select("id").
+		Where("status = ? AND effective_at <= ?", "pending", time.Now()).
+		Order("effective_at ASC, created_at ASC").
+		Find(&due).Error; err != nil {
+		utils.Logger.WithField("error", err.Error()).Error("Failed to fetch due price changes")
+		return 0
+	}
+
+	applied := 0
+	for _, scheduled := range due {
+		ok, err := applyScheduledPriceChange(scheduled.ID)
+		if err != nil {
+			utils.Logger.WithFields(map[string]interface{}{
+				"scheduledPriceChangeId": scheduled.ID,
+				"error":                  err.Error(),
+			}).Error("Failed to apply scheduled price change")
+			continue
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied
+}
+
+// RunPriceScheduler applies due price changes now and then every minute. It
+// never returns; start it in its own goroutine.
+func RunPriceScheduler() {
+	ticker := time.NewTicker(priceSchedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		if applied := ApplyDuePriceChanges(); applied > 0 {
+			utils.Logger.WithField("count", applied).Info("Applied scheduled price changes")
+		}
+		<-ticker.C
+	}
+}
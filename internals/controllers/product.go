@@ -1,28 +1,34 @@
 package controllers
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func CreateProduct(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
 	var body struct {
-		Name          string   `json:"name" binding:"required"`
-		Brand         string   `json:"brand" binding:"required,oneof=Coke Fanta Sprite"`
-		Description   string   `json:"description"`
-		Price         float64  `json:"price" binding:"required,min=0"`
-		OriginalPrice float64  `json:"originalPrice" binding:"required,min=0"`
-		Image         string   `json:"image"`
-		Rating        float64  `json:"rating"`
-		Reviews       int      `json:"reviews"`
-		Category      string   `json:"category"`
-		Volume        string   `json:"volume"`
-		Unit          string   `json:"unit"`
-		Tags          []string `json:"tags"`
+		Name        string         `json:"name" binding:"required,max=100"`
+		Brand       string         `json:"brand" binding:"required"`
+		Description string         `json:"description"`
+		Image       string         `json:"image" binding:"max=500"`
+		CategoryID  string         `json:"categoryId"`
+		Tags        []string       `json:"tags"`
+		Variants    []variantInput `json:"variants" binding:"required,min=1,dive"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -30,101 +36,354 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// Convert tags to JSON
-	tagsJSON := "[]"
-	if len(body.Tags) > 0 {
-		tagsBytes, err := json.Marshal(body.Tags)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags format"})
-			return
-		}
-		tagsJSON = string(tagsBytes)
+	if len(body.Tags) > maxProductTags {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A product can have at most 20 tags"})
+		return
+	}
+
+	if _, ok := findActiveBrand(body.Brand); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or inactive brand"})
+		return
 	}
 
 	product := models.Product{
-		Name:          body.Name,
-		Brand:         body.Brand,
-		Description:   body.Description,
-		Price:         body.Price,
-		OriginalPrice: body.OriginalPrice,
-		Image:         body.Image,
-		Rating:        body.Rating,
-		Reviews:       body.Reviews,
-		Category:      "Soft Drinks",
-		Volume:        "500ml",
-		Unit:          "single",
-		Tags:          tagsJSON,
+		Name:        body.Name,
+		Brand:       body.Brand,
+		Description: body.Description,
+		Image:       body.Image,
 	}
 
-	if body.Category != "" {
-		product.Category = body.Category
+	if body.CategoryID != "" {
+		var category models.Category
+		if err := db.DB.First(&category, "id = ?", body.CategoryID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Category not found"})
+			return
+		}
+		product.CategoryID = &category.ID
+		product.Category = &category
 	}
-	if body.Volume != "" {
-		product.Volume = body.Volume
+
+	skus := map[string]bool{}
+	for _, input := range body.Variants {
+		variant, msg := newVariant(db.DB, "", input)
+		if msg != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+			return
+		}
+		if skus[variant.SKU] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each variant needs its own SKU"})
+			return
+		}
+		skus[variant.SKU] = true
+		product.Variants = append(product.Variants, variant)
+	}
+	// The product's own price is its cheapest variant's until refreshProductSummary
+	// recomputes it below.
+	product.Price = product.Variants[0].Price
+	product.OriginalPrice = product.Variants[0].OriginalPrice
+
+	tx := db.DB.Begin()
+
+	if err := tx.Omit("Category").Create(&product).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+		return
 	}
-	if body.Unit != "" {
-		product.Unit = body.Unit
+
+	for _, variant := range product.Variants {
+		if err := recordInitialPrice(tx, variant, userID.(string)); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record variant prices"})
+			return
+		}
 	}
 
-	if err := db.DB.Create(&product).Error; err != nil {
+	if err := refreshProductSummary(tx, product.ID); err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
 		return
 	}
 
+	if err := setProductTags(tx, &product, body.Tags); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to tag product"})
+		return
+	}
+
+	tx.Commit()
+
+	db.DB.Preload("Category").Preload("Tags").Preload("Variants").First(&product, "id = ?", product.ID)
 	c.JSON(http.StatusCreated, product)
 }
 
+// productSorts are the catalogue orderings. Each is tie-broken on id so that
+// cursors are stable when several products share a value.
+var productSorts = map[string]struct {
+	Column string
+	Desc   bool
+}{
+	"newest":     {"created_at", true},
+	"price_asc":  {"price", false},
+	"price_desc": {"price", true},
+	"rating":     {"rating", true},
+}
+
+// productCursor is the position of the last product on a catalogue page.
+type productCursor struct {
+	Sort      string    `json:"s"`
+	ID        string    `json:"id"`
+	Number    float64   `json:"n,omitempty"` // price or rating
+	CreatedAt time.Time `json:"t,omitempty"`
+}
+
+// filterProducts applies the catalogue filters from the query string:
+// brand (comma-separated), category (id or slug, including subcategories),
+// unit, volume, tag (slug), minPrice, maxPrice.
+func filterProducts(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if brand := c.Query("brand"); brand != "" {
+		query = query.Where("brand IN ?", strings.Split(brand, ","))
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category_id IN ("+categoryTreeSQL+")", category, category)
+	}
+	if unit := c.Query("unit"); unit != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id
+			AND LOWER(v.unit) = LOWER(?) AND v.status = 'active' AND v.deleted_at IS NULL)`, unit)
+	}
+	if volume := c.Query("volume"); volume != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id
+			AND LOWER(v.volume) = LOWER(?) AND v.status = 'active' AND v.deleted_at IS NULL)`, volume)
+	}
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.product_id = products.id AND t.slug = ? AND t.deleted_at IS NULL)`, utils.Slugify(tag))
+	}
+	for param, condition := range map[string]string{"minPrice": "price >= ?", "maxPrice": "price <= ?"} {
+		if value := c.Query(param); value != "" {
+			price, err := strconv.ParseFloat(value, 64)
+			if err != nil || price < 0 {
+				return nil, fmt.Errorf("%s must be a non-negative number", param)
+			}
+			query = query.Where(condition, price)
+		}
+	}
+	return query, nil
+}
+
+// GetAllProducts returns one page of the catalogue. Pass the returned
+// nextCursor as "cursor" to fetch the following page.
 func GetAllProducts(c *gin.Context) {
+	sortName := c.DefaultQuery("sort", "newest")
+	sort, ok := productSorts[sortName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of newest, price_asc, price_desc, rating"})
+		return
+	}
+
+	limit := pageLimit(c)
+
+	query, err := filterProducts(c, db.DB.Model(&models.Product{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count products"})
+		return
+	}
+
+	direction, comparison := "ASC", ">"
+	if sort.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		var cursor productCursor
+		if err := decodeCursor(value, &cursor); err != nil || cursor.Sort != sortName || cursor.ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+
+		var last interface{} = cursor.Number
+		if sort.Column == "created_at" {
+			last = cursor.CreatedAt
+		}
+		query = query.Where(
+			fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", sort.Column, comparison, sort.Column, comparison),
+			last, last, cursor.ID,
+		)
+	}
+
 	var products []models.Product
-	if err := db.DB.Find(&products).Error; err != nil {
+	if err := query.
+		Preload("Category").
+		Preload("Tags").
+		Preload("Variants").
+		Preload("Images", orderedImages).
+		Order(fmt.Sprintf("%s %s, id %s", sort.Column, direction, direction)).
+		Limit(limit + 1).
+		Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
 		return
 	}
 
-	c.JSON(http.StatusOK, products)
+	var nextCursor *string
+	if len(products) > limit {
+		products = products[:limit]
+		last := products[len(products)-1]
+
+		cursor := productCursor{Sort: sortName, ID: last.ID}
+		switch sort.Column {
+		case "price":
+			cursor.Number = last.Price
+		case "rating":
+			cursor.Number = last.Rating
+		default:
+			cursor.CreatedAt = last.CreatedAt
+		}
+		encoded := encodeCursor(cursor)
+		nextCursor = &encoded
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"products": products,
+		"pagination": gin.H{
+			"total":      total,
+			"limit":      limit,
+			"sort":       sortName,
+			"nextCursor": nextCursor,
+			"hasMore":    nextCursor != nil,
+		},
+	})
 }
 
 func GetProduct(c *gin.Context) {
 	productID := c.Param("id")
 
 	var product models.Product
-	if err := db.DB.First(&product, "id = ?", productID).Error; err != nil {
+	if err := db.DB.Preload("Category").Preload("Tags").Preload("Variants").Preload("Images", orderedImages).
+		First(&product, "id = ?", productID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
 
+	c.Header("ETag", versionETag(product.Version))
 	c.JSON(http.StatusOK, product)
 }
 
+// productReadOnly explains why product fields that clients commonly send
+// cannot be patched.
+var productReadOnly = map[string]string{
+	"price":         "set prices on the product's variants",
+	"originalPrice": "set prices on the product's variants",
+	"rating":        "ratings are computed from reviews",
+	"reviews":       "ratings are computed from reviews",
+	"tags":          "use PUT /admin/products/:id/tags",
+	"variants":      "use the variant endpoints",
+	"images":        "use the image endpoints",
+}
+
+// UpdateProduct changes the fields present in the request body and leaves
+// the rest alone. The client sends the ETag it read as If-Match, so that it
+// cannot overwrite someone else's edit, and each change is audited.
 func UpdateProduct(c *gin.Context) {
-	productID := c.Param("id")
+	var body struct {
+		Name        *string `json:"name" binding:"omitempty,min=1,max=100"`
+		Brand       *string `json:"brand" binding:"omitempty,min=1"`
+		Description *string `json:"description"`
+		Image       *string `json:"image" binding:"omitempty,max=500"` // "" removes it
+		CategoryID  *string `json:"categoryId"`                        // "" removes it
+	}
+
+	if !bindPatch(c, &body, productReadOnly) {
+		return
+	}
 
 	var product models.Product
-	if err := db.DB.First(&product, "id = ?", productID).Error; err != nil {
+	if err := db.DB.First(&product, "id = ?", c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
 	}
 
-	var updateData models.Product
-	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+	if !checkIfMatch(c, product.Version, "product") {
 		return
 	}
 
-	// Validate price if provided
-	if updateData.Price < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Price cannot be negative"})
-		return
+	updates := map[string]interface{}{}
+	changes := map[string]fieldChange{}
+
+	if body.Name != nil && *body.Name != product.Name {
+		updates["name"] = *body.Name
+		changes["name"] = fieldChange{product.Name, *body.Name}
+	}
+	if body.Brand != nil && *body.Brand != product.Brand {
+		if _, ok := findActiveBrand(*body.Brand); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or inactive brand"})
+			return
+		}
+		updates["brand"] = *body.Brand
+		changes["brand"] = fieldChange{product.Brand, *body.Brand}
+	}
+	if body.Description != nil && *body.Description != product.Description {
+		updates["description"] = *body.Description
+		changes["description"] = fieldChange{product.Description, *body.Description}
+	}
+	if body.Image != nil && *body.Image != product.Image {
+		updates["image"] = *body.Image
+		changes["image"] = fieldChange{product.Image, *body.Image}
+	}
+	if body.CategoryID != nil {
+		var categoryID *string
+		if *body.CategoryID != "" {
+			var category models.Category
+			if err := db.DB.First(&category, "id = ?", *body.CategoryID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Category not found"})
+				return
+			}
+			categoryID = &category.ID
+		}
+		if (categoryID == nil) != (product.CategoryID == nil) || (categoryID != nil && *categoryID != *product.CategoryID) {
+			updates["category_id"] = categoryID
+			changes["categoryId"] = fieldChange{product.CategoryID, categoryID}
+		}
 	}
 
-	if err := db.DB.Model(&product).Updates(updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
-		return
+	if len(updates) > 0 {
+		tx := db.DB.Begin()
+
+		saved, err := saveVersionedUpdate(tx, c, &models.Product{}, "product", product.ID, product.Version, updates, changes)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+			return
+		}
+		if !saved {
+			tx.Rollback()
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "The product has been changed by someone else; fetch it again and reapply your changes"})
+			return
+		}
+
+		tx.Commit()
 	}
 
+	db.DB.Preload("Category").Preload("Tags").Preload("Variants").Preload("Images", orderedImages).First(&product, "id = ?", product.ID)
+	c.Header("ETag", versionETag(product.Version))
 	c.JSON(http.StatusOK, product)
 }
 
+// GetProductChanges lists the audited edits of a product.
+func GetProductChanges(c *gin.Context) {
+	var product models.Product
+	if err := db.DB.Unscoped().First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	getAuditLog(c, "product", product.ID)
+}
+
 func DeleteProduct(c *gin.Context) {
 	productID := c.Param("id")
 
@@ -142,11 +401,22 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 
-	if err := db.DB.Delete(&product).Error; err != nil {
+	tx := db.DB.Begin()
+
+	if err := tx.Where("product_id = ?", productID).Delete(&models.ProductVariant{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
+		return
+	}
+
+	if err := tx.Delete(&product).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete product"})
 		return
 	}
 
+	tx.Commit()
+
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
 }
 
@@ -169,12 +439,15 @@ func GetProductsByBrand(c *gin.Context) {
 func GetProductStockAcrossBranches(c *gin.Context) {
 	productID := c.Param("id")
 
-	var stocks []models.BranchInventory
-	if err := db.DB.
+	query := db.DB.
 		Preload("Branch").
 		Preload("Product").
-		Where("product_id = ?", productID).
-		Find(&stocks).Error; err != nil {
+		Preload("Variant").
+		Where("product_id = ?", productID)
+	query = scopeToBranches(c, query, "branch_id")
+
+	var stocks []models.BranchInventory
+	if err := query.Find(&stocks).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product stock"})
 		return
 	}
@@ -198,22 +471,21 @@ func GetBranchInventory(c *gin.Context) {
 
 	var inventories []models.BranchInventory
 	if err := db.DB.
-		Preload("Product").
+		Preload("Product.Category").
+		Preload("Product.Tags").
+		Preload("Variant").
 		Where("branch_id = ?", branchID).
 		Find(&inventories).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch branch inventory"})
 		return
 	}
 
-	// Convert to ProductWithStock format matching frontend
+	// Convert to ProductWithStock format matching frontend, one entry per variant
 	products := []gin.H{}
 	for _, inventory := range inventories {
-		// Parse tags JSON to array
-		var tags []string
-		if inventory.Product.Tags != "" && inventory.Product.Tags != "[]" {
-			if err := json.Unmarshal([]byte(inventory.Product.Tags), &tags); err != nil {
-				tags = []string{} // Default to empty array on error
-			}
+		category := ""
+		if inventory.Product.Category != nil {
+			category = inventory.Product.Category.Name
 		}
 
 		products = append(products, gin.H{
@@ -221,17 +493,22 @@ func GetBranchInventory(c *gin.Context) {
 			"name":          inventory.Product.Name,
 			"brand":         inventory.Product.Brand,
 			"description":   inventory.Product.Description,
-			"price":         inventory.Product.Price,
-			"originalPrice": inventory.Product.OriginalPrice,
+			"variantId":     inventory.Variant.ID,
+			"variantName":   inventory.Variant.Name,
+			"sku":           inventory.Variant.SKU,
+			"barcode":       inventory.Variant.Barcode,
+			"price":         inventory.Variant.Price,
+			"originalPrice": inventory.Variant.OriginalPrice,
 			"image":         inventory.Product.Image,
 			"rating":        inventory.Product.Rating,
 			"reviews":       inventory.Product.Reviews,
 			"stock":         inventory.Quantity,
-			"category":      inventory.Product.Category,
-			"volume":        inventory.Product.Volume,
-			"unit":          inventory.Product.Unit,
-			"tags":          tags,
-			"available":     inventory.Quantity > 0,
+			"category":      category,
+			"volume":        inventory.Variant.Volume,
+			"unit":          inventory.Variant.Unit,
+			"packSize":      inventory.Variant.PackSize,
+			"tags":          tagNames(inventory.Product.Tags),
+			"available":     inventory.Quantity > 0 && inventory.Variant.Status == "active",
 		})
 	}
 
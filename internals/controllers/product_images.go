@@ -0,0 +1,306 @@
+// product images controller
+package controllers
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	maxImageSize     = 5 << 20 // 5 MB
+	maxProductImages = 10
+)
+
+// imageSizes are the widths of the generated copies of each upload.
+var imageSizes = []struct {
+	Name  string
+	Width int
+}{
+	{"medium", 800},
+	{"thumbnail", 200},
+}
+
+// orderedImages sorts a product's images into display order.
+func orderedImages(tx *gorm.DB) *gorm.DB {
+	return tx.Order("position ASC, created_at ASC")
+}
+
+// refreshProductImage copies the first image's URL to Product.Image, which
+// older clients still read. A product whose uploads have all been removed
+// keeps any external image URL it had.
+func refreshProductImage(tx *gorm.DB, productID string, removedURL string) error {
+	var first models.ProductImage
+	err := orderedImages(tx).Where("product_id = ?", productID).First(&first).Error
+	if err == nil {
+		return tx.Model(&models.Product{}).Where("id = ?", productID).Update("image", first.URL).Error
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if removedURL != "" {
+		return tx.Model(&models.Product{}).Where("id = ? AND image = ?", productID, removedURL).Update("image", "").Error
+	}
+	return nil
+}
+
+// deleteStoredFiles removes an image's files. Failures are logged rather than
+// returned; an orphaned file is harmless.
+func deleteStoredFiles(storage services.Storage, keys []string) {
+	for _, key := range keys {
+		if err := storage.Delete(key); err != nil {
+			utils.Logger.WithFields(map[string]interface{}{
+				"key":   key,
+				"error": err.Error(),
+			}).Warn("Failed to delete stored image file")
+		}
+	}
+}
+
+// UploadProductImage stores an image sent as multipart "image" along with
+// medium and thumbnail copies, and appends it to the product's images.
+// An optional "altText" field describes the image.
+func UploadProductImage(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var product models.Product
+	if err := db.DB.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	var count int64
+	db.DB.Model(&models.ProductImage{}).Where("product_id = ?", product.ID).Count(&count)
+	if count >= maxProductImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A product can have at most %d images", maxProductImages)})
+		return
+	}
+
+	// Leave room for the multipart framing and the other fields
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageSize+(1<<20))
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image file is required in the 'image' field and must be at most 5 MB"})
+		return
+	}
+	if file.Size > maxImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image must be at most 5 MB"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxImageSize+1))
+	if err != nil || len(data) > maxImageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+
+	img, contentType, err := utils.DecodeImage(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	altText := strings.TrimSpace(c.PostForm("altText"))
+	if len(altText) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "altText must be at most 255 characters"})
+		return
+	}
+
+	// A random name per upload so that replaced images are never served from cache
+	name := make([]byte, 8)
+	if _, err := rand.Read(name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	storage := services.NewStorage()
+	prefix := fmt.Sprintf("products/%s/%x", product.ID, name)
+
+	image := models.ProductImage{
+		ProductID:   product.ID,
+		Position:    int(count),
+		AltText:     altText,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		UploadedBy:  userID.(string),
+	}
+
+	keys := []string{prefix + "/original" + utils.ImageTypes[contentType]}
+	if err := storage.Put(keys[0], data, contentType); err != nil {
+		utils.Logger.WithField("error", err.Error()).Error("Failed to store product image")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	image.URL = storage.URL(keys[0])
+
+	for _, size := range imageSizes {
+		resized, resizedType, err := utils.EncodeImage(utils.ResizeImage(img, size.Width), contentType)
+		if err == nil {
+			key := prefix + "/" + size.Name + utils.ImageTypes[resizedType]
+			if err = storage.Put(key, resized, resizedType); err == nil {
+				keys = append(keys, key)
+				if size.Name == "medium" {
+					image.MediumURL = storage.URL(key)
+				} else {
+					image.ThumbnailURL = storage.URL(key)
+				}
+			}
+		}
+		if err != nil {
+			deleteStoredFiles(storage, keys)
+			utils.Logger.WithField("error", err.Error()).Error("Failed to store product image thumbnail")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+			return
+		}
+	}
+	image.StorageKeys = strings.Join(keys, " ")
+
+	tx := db.DB.Begin()
+
+	if err := tx.Create(&image).Error; err != nil {
+		tx.Rollback()
+		deleteStoredFiles(storage, keys)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save image"})
+		return
+	}
+
+	if err := refreshProductImage(tx, product.ID, ""); err != nil {
+		tx.Rollback()
+		deleteStoredFiles(storage, keys)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, image)
+}
+
+// GetProductImages lists a product's images in display order.
+func GetProductImages(c *gin.Context) {
+	var product models.Product
+	if err := db.DB.Preload("Images", orderedImages).First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, product.Images)
+}
+
+// ReorderProductImages sets the display order of all of a product's images.
+func ReorderProductImages(c *gin.Context) {
+	var body struct {
+		ImageIDs []string `json:"imageIds" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	productID := c.Param("id")
+
+	var images []models.ProductImage
+	if err := db.DB.Where("product_id = ?", productID).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product images"})
+		return
+	}
+
+	known := map[string]bool{}
+	for _, image := range images {
+		known[image.ID] = true
+	}
+	seen := map[string]bool{}
+	for _, id := range body.ImageIDs {
+		if !known[id] || seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "imageIds must list each of the product's images exactly once"})
+			return
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(images) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "imageIds must list each of the product's images exactly once"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	for position, id := range body.ImageIDs {
+		if err := tx.Model(&models.ProductImage{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder images"})
+			return
+		}
+	}
+
+	if err := refreshProductImage(tx, productID, ""); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	tx.Commit()
+
+	db.DB.Scopes(orderedImages).Where("product_id = ?", productID).Find(&images)
+	c.JSON(http.StatusOK, images)
+}
+
+// DeleteProductImage removes an image and its stored files. The remaining
+// images close up the gap.
+func DeleteProductImage(c *gin.Context) {
+	var image models.ProductImage
+	if err := db.DB.Where("id = ? AND product_id = ?", c.Param("imageId"), c.Param("id")).First(&image).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Unscoped().Delete(&image).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+
+	if err := tx.Model(&models.ProductImage{}).
+		Where("product_id = ? AND position > ?", image.ProductID, image.Position).
+		Update("position", gorm.Expr("position - 1")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder images"})
+		return
+	}
+
+	if err := refreshProductImage(tx, image.ProductID, image.URL); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	tx.Commit()
+
+	deleteStoredFiles(services.NewStorage(), strings.Fields(image.StorageKeys))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+}
@@ -0,0 +1,218 @@
+// customer profile and account activity controller
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultActivityLimit = 50
+
+// recordActivity adds an event to userID's account activity feed. Failures
+// are logged rather than failing the request that triggered them.
+func recordActivity(c *gin.Context, userID, event, details string) {
+	activity := models.AccountActivity{
+		UserID:    userID,
+		Event:     event,
+		Details:   truncate(details, 255),
+		IPAddress: c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 255),
+	}
+	if err := db.DB.Create(&activity).Error; err != nil {
+		utils.Logger.WithError(err).WithField("event", event).Error("Failed to record account activity")
+	}
+}
+
+// UpdateProfile changes the caller's name, email, phone or preferred branch.
+// A new email or phone must be verified again, and changing the email needs
+// the current password since it controls password resets.
+func UpdateProfile(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Name              *string `json:"name" binding:"omitempty,min=1,max=100"`
+		Email             *string `json:"email" binding:"omitempty,email"`
+		Phone             *string `json:"phone"`
+		PreferredBranchID *string `json:"preferredBranchId"` // "" clears it
+		CurrentPassword   string  `json:"currentPassword"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if body.Name != nil && strings.TrimSpace(*body.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name cannot be empty"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	changes := []string{}
+
+	if body.Name != nil && strings.TrimSpace(*body.Name) != user.Name {
+		updates["name"] = strings.TrimSpace(*body.Name)
+		changes = append(changes, "name")
+	}
+
+	emailChanged := body.Email != nil && !strings.EqualFold(*body.Email, user.Email)
+	if emailChanged {
+		if !utils.CheckPassword(body.CurrentPassword, user.Password) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is required to change your email"})
+			return
+		}
+
+		var existingUser models.User
+		if err := db.DB.Where("email = ? AND id <> ?", *body.Email, user.ID).First(&existingUser).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+			return
+		}
+
+		updates["email"] = *body.Email
+		updates["email_verified_at"] = nil
+		changes = append(changes, "email")
+	}
+
+	phoneChanged := false
+	if body.Phone != nil {
+		phone, err := utils.NormalizePhone(*body.Phone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if phone != user.Phone {
+			var existingUser models.User
+			if err := db.DB.Where("phone = ? AND id <> ?", phone, user.ID).First(&existingUser).Error; err == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "Phone already exists"})
+				return
+			}
+
+			updates["phone"] = phone
+			updates["phone_verified_at"] = nil
+			changes = append(changes, "phone")
+			phoneChanged = true
+		}
+	}
+
+	if body.PreferredBranchID != nil {
+		if *body.PreferredBranchID == "" {
+			updates["preferred_branch_id"] = nil
+		} else {
+			var branch models.Branch
+			if err := db.DB.Where("id = ? AND status = ?", *body.PreferredBranchID, "active").First(&branch).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Branch not found or inactive"})
+				return
+			}
+			updates["preferred_branch_id"] = branch.ID
+		}
+		changes = append(changes, "preferred branch")
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+
+	oldEmail := user.Email
+	if err := db.DB.Model(&user).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	if emailChanged {
+		// Links already sent to the old address must not work for the new one
+		db.DB.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose IN ? AND used_at IS NULL", user.ID, []string{"email_verification", "password_reset"}).
+			Update("used_at", time.Now())
+
+		if err := services.NewNotifier().Send(services.Notification{
+			Channel: "email",
+			To:      oldEmail,
+			Subject: "Your email address was changed",
+			Body: fmt.Sprintf("Hi %s,\n\nThe email address on your account was changed to %s. If you did not do this, contact support immediately.",
+				user.Name, user.Email),
+		}); err != nil {
+			utils.Logger.WithError(err).Error("Failed to notify old email address")
+		}
+
+		if err := sendEmailVerification(user); err != nil {
+			utils.Logger.WithError(err).Error("Failed to send email verification")
+		}
+	}
+	if phoneChanged {
+		db.DB.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose IN ? AND used_at IS NULL", user.ID, []string{"phone_verification", "login_otp"}).
+			Update("used_at", time.Now())
+	}
+
+	recordActivity(c, user.ID, "profile_updated", "Changed "+strings.Join(changes, ", "))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Profile updated successfully",
+		"id":                user.ID,
+		"name":              user.Name,
+		"email":             user.Email,
+		"phone":             user.Phone,
+		"emailVerified":     user.EmailVerifiedAt != nil,
+		"phoneVerified":     user.PhoneVerifiedAt != nil,
+		"preferredBranchId": user.PreferredBranchID,
+	})
+}
+
+// GetAccountActivity lists recent security events on the caller's account,
+// newest first.
+func GetAccountActivity(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultActivityLimit)))
+	if err != nil || limit < 1 || limit > 200 {
+		limit = defaultActivityLimit
+	}
+
+	var activities []models.AccountActivity
+	if err := db.DB.Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch account activity"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, activity := range activities {
+		result = append(result, gin.H{
+			"id":        activity.ID,
+			"event":     activity.Event,
+			"details":   activity.Details,
+			"ipAddress": activity.IPAddress,
+			"userAgent": activity.UserAgent,
+			"createdAt": activity.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,379 @@
+// purchase orders controller
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// purchaseOrderResponse and goodsReceivedNoteResponse cut the users who
+// raised an order or received the goods down to their id and name, so that
+// account details are not exposed.
+type purchaseOrderResponse struct {
+	models.PurchaseOrder
+	CreatedByUser gin.H
+}
+
+type goodsReceivedNoteResponse struct {
+	models.GoodsReceivedNote
+	ReceivedByUser gin.H
+}
+
+func CreatePurchaseOrder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		SupplierID           string `json:"supplierId" binding:"required"`
+		BranchID             string `json:"branchId" binding:"required"`
+		ExpectedDeliveryDate string `json:"expectedDeliveryDate"` // YYYY-MM-DD
+		Notes                string `json:"notes"`
+		Lines                []struct {
+			ProductID string  `json:"productId"` // Enough on its own for single-variant products
+			VariantID string  `json:"variantId"`
+			SKU       string  `json:"sku"`
+			Quantity  int     `json:"quantity" binding:"required,min=1"`
+			UnitCost  float64 `json:"unitCost" binding:"min=0"`
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := db.DB.First(&supplier, "id = ?", body.SupplierID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+	if supplier.Status != "active" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Supplier is inactive"})
+		return
+	}
+
+	var branch models.Branch
+	if err := db.DB.First(&branch, "id = ?", body.BranchID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+		return
+	}
+
+	order := models.PurchaseOrder{
+		SupplierID: body.SupplierID,
+		BranchID:   body.BranchID,
+		Status:     "open",
+		Notes:      body.Notes,
+		CreatedBy:  userID.(string),
+	}
+
+	expectedDeliveryDate, err := parseOptionalDate(body.ExpectedDeliveryDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expectedDeliveryDate, use YYYY-MM-DD"})
+		return
+	}
+	order.ExpectedDeliveryDate = expectedDeliveryDate
+
+	seenVariants := map[string]bool{}
+	for _, line := range body.Lines {
+		variant, err := resolveVariant(db.DB, line.ProductID, line.VariantID, line.SKU)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		if seenVariants[variant.ID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each variant may appear only once per purchase order"})
+			return
+		}
+		seenVariants[variant.ID] = true
+
+		order.Lines = append(order.Lines, models.PurchaseOrderLine{
+			ProductID:       variant.ProductID,
+			VariantID:       variant.ID,
+			QuantityOrdered: line.Quantity,
+			UnitCost:        line.UnitCost,
+		})
+	}
+
+	if err := db.DB.Create(&order).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create purchase order"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+func GetPurchaseOrders(c *gin.Context) {
+	supplierID := c.Query("supplierId")
+	branchID := c.Query("branchId")
+	status := c.Query("status")
+
+	query := db.DB.Model(&models.PurchaseOrder{}).
+		Preload("Supplier").
+		Preload("Branch").
+		Preload("Lines.Product").
+		Preload("Lines.Variant")
+
+	if supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+	if branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.PurchaseOrder
+	if err := query.Order("created_at DESC").Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase orders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func GetPurchaseOrder(c *gin.Context) {
+	orderID := c.Param("id")
+
+	var order models.PurchaseOrder
+	if err := db.DB.
+		Preload("Supplier").
+		Preload("Branch").
+		Preload("CreatedByUser").
+		Preload("Lines.Product").
+		Preload("Lines.Variant").
+		First(&order, "id = ?", orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, purchaseOrderResponse{
+		PurchaseOrder: order,
+		CreatedByUser: userRef(&order.CreatedByUser),
+	})
+}
+
+func CancelPurchaseOrder(c *gin.Context) {
+	orderID := c.Param("id")
+
+	var order models.PurchaseOrder
+	if err := db.DB.First(&order, "id = ?", orderID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return
+	}
+
+	// Stock already received stays in the books, so only untouched orders can be cancelled
+	if order.Status != "open" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only open purchase orders with no receipts can be cancelled"})
+		return
+	}
+
+	if err := db.DB.Model(&order).Update("status", "cancelled").Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel purchase order"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Purchase order cancelled successfully"})
+}
+
+// ReceivePurchaseOrder records a goods-received note against a purchase order
+// and posts each received line to the branch inventory as a restock.
+func ReceivePurchaseOrder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	orderID := c.Param("id")
+
+	var body struct {
+		DeliveryNoteRef string `json:"deliveryNoteRef"`
+		Notes           string `json:"notes"`
+		Lines           []struct {
+			LineID      string   `json:"lineId" binding:"required"`
+			Quantity    int      `json:"quantity" binding:"required,min=1"`
+			UnitCost    *float64 `json:"unitCost" binding:"omitempty,min=0"` // defaults to the PO line cost
+			BatchNumber string   `json:"batchNumber"`
+			ExpiryDate  string   `json:"expiryDate"` // YYYY-MM-DD
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	var order models.PurchaseOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&order, "id = ?", orderID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Purchase order not found"})
+		return
+	}
+
+	if order.Status != "open" && order.Status != "partially_received" {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Purchase order is not open for receiving"})
+		return
+	}
+
+	var lines []models.PurchaseOrderLine
+	if err := tx.Where("purchase_order_id = ?", order.ID).Find(&lines).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchase order lines"})
+		return
+	}
+
+	linesByID := map[string]*models.PurchaseOrderLine{}
+	for i := range lines {
+		linesByID[lines[i].ID] = &lines[i]
+	}
+
+	note := models.GoodsReceivedNote{
+		PurchaseOrderID: order.ID,
+		BranchID:        order.BranchID,
+		ReceivedBy:      userID.(string),
+		DeliveryNoteRef: body.DeliveryNoteRef,
+		Notes:           body.Notes,
+	}
+
+	if err := tx.Create(&note).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create goods received note"})
+		return
+	}
+
+	for _, received := range body.Lines {
+		line, ok := linesByID[received.LineID]
+		if !ok {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Line does not belong to this purchase order: " + received.LineID})
+			return
+		}
+
+		outstanding := line.QuantityOrdered - line.QuantityReceived
+		if received.Quantity > outstanding {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":       "Received quantity exceeds outstanding quantity",
+				"lineId":      line.ID,
+				"outstanding": outstanding,
+			})
+			return
+		}
+
+		unitCost := line.UnitCost
+		if received.UnitCost != nil {
+			unitCost = *received.UnitCost
+		}
+
+		expiryDate, err := parseOptionalDate(received.ExpiryDate)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiryDate, use YYYY-MM-DD"})
+			return
+		}
+
+		restockLog := models.RestockLog{
+			BranchID:            order.BranchID,
+			ProductID:           line.ProductID,
+			VariantID:           line.VariantID,
+			QuantityAdded:       received.Quantity,
+			UnitCost:            &unitCost,
+			PurchaseOrderID:     &order.ID,
+			GoodsReceivedNoteID: &note.ID,
+			BatchNumber:         received.BatchNumber,
+			ExpiryDate:          expiryDate,
+			RestockedBy:         userID.(string),
+		}
+
+		if err := applyRestock(tx, &restockLog); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		receivedLine := models.GoodsReceivedLine{
+			GoodsReceivedNoteID: note.ID,
+			PurchaseOrderLineID: line.ID,
+			ProductID:           line.ProductID,
+			VariantID:           line.VariantID,
+			Quantity:            received.Quantity,
+			UnitCost:            unitCost,
+			RestockLogID:        restockLog.ID,
+		}
+
+		if err := tx.Create(&receivedLine).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record received line"})
+			return
+		}
+		note.Lines = append(note.Lines, receivedLine)
+
+		line.QuantityReceived += received.Quantity
+		if err := tx.Model(line).Update("quantity_received", line.QuantityReceived).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order line"})
+			return
+		}
+	}
+
+	status := "received"
+	for _, line := range lines {
+		if line.QuantityReceived < line.QuantityOrdered {
+			status = "partially_received"
+			break
+		}
+	}
+
+	if err := tx.Model(&order).Update("status", status).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update purchase order status"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":             "Goods received successfully",
+		"goodsReceivedNote":   note,
+		"purchaseOrderStatus": status,
+	})
+}
+
+func GetPurchaseOrderReceipts(c *gin.Context) {
+	orderID := c.Param("id")
+
+	var notes []models.GoodsReceivedNote
+	if err := db.DB.
+		Preload("ReceivedByUser").
+		Preload("Lines.Product").
+		Preload("Lines.Variant").
+		Where("purchase_order_id = ?", orderID).
+		Order("created_at DESC").
+		Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goods received notes"})
+		return
+	}
+
+	results := []goodsReceivedNoteResponse{}
+	for _, note := range notes {
+		results = append(results, goodsReceivedNoteResponse{
+			GoodsReceivedNote: note,
+			ReceivedByUser:    userRef(&note.ReceivedByUser),
+		})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,341 @@
+// bulk restock and inventory import/export controller
+package controllers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+)
+
+const maxBulkRestockLines = 1000
+
+// restockCSVColumns are the accepted CSV headers; branchId and quantity are
+// required along with one of productId, variantId or sku, and the rest may be
+// omitted or left blank.
+var restockCSVColumns = []string{"branchId", "quantity", "productId", "variantId", "sku", "unitCost", "batchNumber", "expiryDate"}
+
+// BulkRestock applies many restock lines in a single transaction. Lines are
+// sent either as JSON ({"lines": [...]}) or as a CSV file in the "file" field
+// of a multipart upload. Every line is validated before any is applied; if one
+// fails, nothing is written and the per-line errors are returned.
+func BulkRestock(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var lines []RestockRequest
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required in the 'file' field"})
+			return
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+
+		lines, err = parseRestockCSV(f)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		var body struct {
+			Lines []RestockRequest `json:"lines" binding:"required,min=1"`
+		}
+		// Lines are validated individually below so each gets its own error
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+		lines = body.Lines
+	}
+
+	if len(lines) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No restock lines provided"})
+		return
+	}
+	if len(lines) > maxBulkRestockLines {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A bulk restock may contain at most %d lines", maxBulkRestockLines)})
+		return
+	}
+
+	expiryDates, results, valid, err := validateRestockLines(c, lines)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up branches and product variants"})
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "One or more lines are invalid; no stock was changed",
+			"results": results,
+		})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	for i, line := range lines {
+		restockLog := models.RestockLog{
+			BranchID:      line.BranchID,
+			ProductID:     line.ProductID,
+			VariantID:     line.VariantID,
+			QuantityAdded: line.Quantity,
+			UnitCost:      line.UnitCost,
+			BatchNumber:   line.BatchNumber,
+			ExpiryDate:    expiryDates[i],
+			RestockedBy:   userID.(string),
+		}
+
+		if err := applyRestock(tx, &restockLog); err != nil {
+			tx.Rollback()
+			results[i]["status"] = "error"
+			results[i]["error"] = err.Error()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Bulk restock failed; no stock was changed",
+				"results": results,
+			})
+			return
+		}
+
+		results[i]["status"] = "applied"
+		results[i]["restockLogId"] = restockLog.ID
+		results[i]["previousQty"] = restockLog.PreviousQuantity
+		results[i]["newQty"] = restockLog.NewQuantity
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bulk restock applied successfully",
+		"applied": len(lines),
+		"results": results,
+	})
+}
+
+// validateRestockLines checks every line and returns a result entry per line.
+// Branches and variants are looked up once for the whole batch, and each
+// valid line has its product and variant ids filled in. An error means the
+// lookups failed, not that a line is invalid.
+func validateRestockLines(c *gin.Context, lines []RestockRequest) ([]*time.Time, []gin.H, bool, error) {
+	branchIDs := []string{}
+	productIDs, variantIDs, skus := []string{}, []string{}, []string{}
+	for _, line := range lines {
+		branchIDs = append(branchIDs, line.BranchID)
+		productIDs = append(productIDs, line.ProductID)
+		variantIDs = append(variantIDs, line.VariantID)
+		skus = append(skus, line.SKU)
+	}
+
+	knownBranches := map[string]bool{}
+	var branches []models.Branch
+	if err := db.DB.Where("id IN ?", branchIDs).Find(&branches).Error; err != nil {
+		return nil, nil, false, err
+	}
+	for _, branch := range branches {
+		knownBranches[branch.ID] = true
+	}
+
+	variants, err := findVariantCandidates(db.DB, productIDs, variantIDs, skus)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	expiryDates := make([]*time.Time, len(lines))
+	results := make([]gin.H, len(lines))
+	valid := true
+
+	for i := range lines {
+		line := &lines[i]
+		errs := []string{}
+
+		if line.BranchID == "" {
+			errs = append(errs, "branchId is required")
+		} else if !knownBranches[line.BranchID] {
+			errs = append(errs, "branch not found")
+		} else if !canAccessBranch(c, line.BranchID) {
+			errs = append(errs, "no access to this branch")
+		}
+		if line.ProductID == "" && line.VariantID == "" && line.SKU == "" {
+			errs = append(errs, "one of variantId, sku or productId is required")
+		} else {
+			variant, err := pickVariant(variants, line.ProductID, line.VariantID, line.SKU)
+			switch {
+			case errors.Is(err, errVariantRequired):
+				errs = append(errs, "product has several variants, variantId or sku is required")
+			case err != nil:
+				errs = append(errs, "product variant not found")
+			default:
+				line.ProductID = variant.ProductID
+				line.VariantID = variant.ID
+			}
+		}
+		if line.Quantity < 1 {
+			errs = append(errs, "quantity must be at least 1")
+		}
+		if line.UnitCost != nil && *line.UnitCost < 0 {
+			errs = append(errs, "unitCost cannot be negative")
+		}
+
+		expiryDate, err := parseOptionalDate(line.ExpiryDate)
+		if err != nil {
+			errs = append(errs, "expiryDate must be YYYY-MM-DD")
+		}
+		expiryDates[i] = expiryDate
+
+		results[i] = gin.H{
+			"line":      i + 1,
+			"branchId":  line.BranchID,
+			"productId": line.ProductID,
+			"variantId": line.VariantID,
+			"sku":       line.SKU,
+			"quantity":  line.Quantity,
+			"status":    "valid",
+		}
+		if len(errs) > 0 {
+			valid = false
+			results[i]["status"] = "invalid"
+			results[i]["errors"] = errs
+		}
+	}
+
+	return expiryDates, results, valid, nil
+}
+
+// parseRestockCSV reads restock lines from a CSV with a header row. Values
+// that cannot be parsed are left at their zero value so that validation
+// reports them against the right line.
+func parseRestockCSV(r io.Reader) ([]RestockRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("CSV file is empty or unreadable")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range restockCSVColumns[:2] {
+		if _, ok := columns[required]; !ok {
+			return nil, errors.New("CSV header must include branchId, quantity and one of productId, variantId or sku")
+		}
+	}
+	identified := false
+	for _, name := range restockCSVColumns[2:5] {
+		if _, ok := columns[name]; ok {
+			identified = true
+		}
+	}
+	if !identified {
+		return nil, errors.New("CSV header must include branchId, quantity and one of productId, variantId or sku")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	lines := []RestockRequest{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV on line %d", len(lines)+2)
+		}
+
+		quantity, _ := strconv.Atoi(field(record, "quantity"))
+		line := RestockRequest{
+			BranchID:    field(record, "branchId"),
+			ProductID:   field(record, "productId"),
+			VariantID:   field(record, "variantId"),
+			SKU:         field(record, "sku"),
+			Quantity:    quantity,
+			BatchNumber: field(record, "batchNumber"),
+			ExpiryDate:  field(record, "expiryDate"),
+		}
+
+		if value := field(record, "unitCost"); value != "" {
+			unitCost, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				unitCost = -1 // reported as invalid by validation
+			}
+			line.UnitCost = &unitCost
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// ExportInventoryCSV streams the same rows as GetInventory as a CSV download.
+func ExportInventoryCSV(c *gin.Context) {
+	branchID := c.Query("branchId")
+
+	query := db.DB.Model(&models.BranchInventory{}).
+		Preload("Branch").
+		Preload("Product").
+		Preload("Variant")
+	query = scopeToBranches(c, query, "branch_id")
+
+	if branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+
+	var inventories []models.BranchInventory
+	if err := query.Order("branch_id ASC").Find(&inventories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
+		return
+	}
+
+	filename := fmt.Sprintf("inventory-%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{
+		"branchId", "branchName", "productId", "productName", "brand",
+		"variantId", "sku", "variantName", "quantity", "averageCost", "lastRestocked", "lowStock",
+	})
+
+	for _, inventory := range inventories {
+		writer.Write([]string{
+			inventory.BranchID,
+			inventory.Branch.Name,
+			inventory.ProductID,
+			inventory.Product.Name,
+			inventory.Product.Brand,
+			inventory.VariantID,
+			inventory.Variant.SKU,
+			inventory.Variant.Name,
+			strconv.Itoa(inventory.Quantity),
+			strconv.FormatFloat(inventory.AverageCost, 'f', 2, 64),
+			inventory.LastRestocked.Format(time.RFC3339),
+			strconv.FormatBool(inventory.Quantity <= lowStockThreshold),
+		})
+	}
+
+	writer.Flush()
+}
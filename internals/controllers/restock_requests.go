@@ -0,0 +1,397 @@
+// restock requests controller
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// restockRequestResponse is a restock request as returned to clients. The
+// requesting and reviewing users are cut down to their id and name so that
+// account details are not exposed.
+type restockRequestResponse struct {
+	models.RestockRequest
+	RequestedByUser gin.H
+	ReviewedByUser  gin.H
+}
+
+func restockRequestView(request models.RestockRequest) restockRequestResponse {
+	return restockRequestResponse{
+		RestockRequest:  request,
+		RequestedByUser: userRef(&request.RequestedByUser),
+		ReviewedByUser:  userRef(request.ReviewedByUser),
+	}
+}
+
+func restockRequestViews(requests []models.RestockRequest) []restockRequestResponse {
+	views := []restockRequestResponse{}
+	for _, request := range requests {
+		views = append(views, restockRequestView(request))
+	}
+	return views
+}
+
+// CreateRestockRequest lets branch staff ask HQ for stock. Staff may only
+// request stock for branches they are assigned to; HQ admins for any.
+func CreateRestockRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		BranchID string `json:"branchId"`
+		Notes    string `json:"notes"`
+		Lines    []struct {
+			ProductID string `json:"productId"` // Enough on its own for single-variant products
+			VariantID string `json:"variantId"`
+			SKU       string `json:"sku"`
+			Quantity  int    `json:"quantity" binding:"required,min=1"`
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	branchID := body.BranchID
+	if branchIDs := scopedBranchIDs(c); branchIDs != nil {
+		// Staff assigned to a single branch need not name it
+		if branchID == "" && len(branchIDs) == 1 {
+			branchID = branchIDs[0]
+		}
+		if !canAccessBranch(c, branchID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this branch"})
+			return
+		}
+	}
+
+	var branch models.Branch
+	if err := db.DB.First(&branch, "id = ?", branchID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+		return
+	}
+
+	request := models.RestockRequest{
+		BranchID:    branchID,
+		RequestedBy: userID.(string),
+		Status:      "pending",
+		Notes:       body.Notes,
+	}
+
+	for _, line := range body.Lines {
+		variant, err := resolveVariant(db.DB, line.ProductID, line.VariantID, line.SKU)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		request.Lines = append(request.Lines, models.RestockRequestLine{
+			ProductID:         variant.ProductID,
+			VariantID:         variant.ID,
+			QuantityRequested: line.Quantity,
+		})
+	}
+
+	if err := db.DB.Create(&request).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create restock request"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetMyRestockRequests lists restock requests for the caller's branches
+// (staff) or raised by the caller (HQ admins).
+func GetMyRestockRequests(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := db.DB.Model(&models.RestockRequest{}).
+		Preload("Branch").
+		Preload("RequestedByUser").
+		Preload("ReviewedByUser").
+		Preload("Lines.Product").
+		Preload("Lines.Variant")
+
+	if scopedBranchIDs(c) != nil {
+		query = scopeToBranches(c, query, "branch_id")
+	} else {
+		query = query.Where("requested_by = ?", userID)
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []models.RestockRequest
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch restock requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, restockRequestViews(requests))
+}
+
+func CancelRestockRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	requestID := c.Param("id")
+
+	var request models.RestockRequest
+	if err := db.DB.Where("id = ? AND requested_by = ?", requestID, userID).First(&request).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Restock request not found"})
+		return
+	}
+
+	if request.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending restock requests can be cancelled"})
+		return
+	}
+
+	if err := db.DB.Model(&request).Update("status", "cancelled").Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel restock request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Restock request cancelled successfully"})
+}
+
+func GetRestockRequests(c *gin.Context) {
+	branchID := c.Query("branchId")
+	status := c.Query("status")
+
+	query := db.DB.Model(&models.RestockRequest{}).
+		Preload("Branch").
+		Preload("RequestedByUser").
+		Preload("ReviewedByUser").
+		Preload("Lines.Product").
+		Preload("Lines.Variant")
+
+	if branchID != "" {
+		query = query.Where("branch_id = ?", branchID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []models.RestockRequest
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch restock requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, restockRequestViews(requests))
+}
+
+func GetRestockRequest(c *gin.Context) {
+	requestID := c.Param("id")
+
+	var request models.RestockRequest
+	if err := db.DB.
+		Preload("Branch").
+		Preload("RequestedByUser").
+		Preload("ReviewedByUser").
+		Preload("Lines.Product").
+		Preload("Lines.Variant").
+		First(&request, "id = ?", requestID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Restock request not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, restockRequestView(request))
+}
+
+// ApproveRestockRequest applies a pending request to branch inventory. Lines
+// may be amended by passing a different quantity (0 drops the line); lines
+// that are not mentioned are approved as requested.
+func ApproveRestockRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	requestID := c.Param("id")
+
+	var body struct {
+		ReviewNotes string `json:"reviewNotes"`
+		Lines       []struct {
+			LineID   string `json:"lineId" binding:"required"`
+			Quantity *int   `json:"quantity" binding:"required,min=0"`
+		} `json:"lines" binding:"dive"`
+	}
+
+	// An empty body approves every line as requested
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	var request models.RestockRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&request, "id = ?", requestID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Restock request not found"})
+		return
+	}
+
+	if request.Status != "pending" {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending restock requests can be approved"})
+		return
+	}
+
+	var lines []models.RestockRequestLine
+	if err := tx.Where("restock_request_id = ?", request.ID).Find(&lines).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch restock request lines"})
+		return
+	}
+
+	amendments := map[string]int{}
+	for _, amended := range body.Lines {
+		amendments[amended.LineID] = *amended.Quantity
+	}
+
+	knownLines := map[string]bool{}
+	for _, line := range lines {
+		knownLines[line.ID] = true
+	}
+	for lineID := range amendments {
+		if !knownLines[lineID] {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Line does not belong to this restock request: " + lineID})
+			return
+		}
+	}
+
+	approverID := userID.(string)
+	applied := []gin.H{}
+
+	for i := range lines {
+		line := &lines[i]
+
+		quantity := line.QuantityRequested
+		if amended, ok := amendments[line.ID]; ok {
+			quantity = amended
+		}
+
+		updates := map[string]interface{}{"quantity_approved": quantity}
+
+		if quantity > 0 {
+			restockLog := models.RestockLog{
+				BranchID:         request.BranchID,
+				ProductID:        line.ProductID,
+				VariantID:        line.VariantID,
+				QuantityAdded:    quantity,
+				RestockRequestID: &request.ID,
+				RequestedBy:      &request.RequestedBy,
+				RestockedBy:      approverID,
+			}
+
+			if err := applyRestock(tx, &restockLog); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+
+			updates["restock_log_id"] = restockLog.ID
+			applied = append(applied, gin.H{
+				"lineId":      line.ID,
+				"productId":   line.ProductID,
+				"variantId":   line.VariantID,
+				"requested":   line.QuantityRequested,
+				"approved":    quantity,
+				"previousQty": restockLog.PreviousQuantity,
+				"newQty":      restockLog.NewQuantity,
+			})
+		}
+
+		if err := tx.Model(line).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update restock request line"})
+			return
+		}
+	}
+
+	now := time.Now()
+	if err := tx.Model(&request).Updates(map[string]interface{}{
+		"status":       "approved",
+		"reviewed_by":  approverID,
+		"review_notes": body.ReviewNotes,
+		"reviewed_at":  &now,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update restock request"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Restock request approved successfully",
+		"applied": applied,
+	})
+}
+
+func RejectRestockRequest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	requestID := c.Param("id")
+
+	var body struct {
+		ReviewNotes string `json:"reviewNotes" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason for rejection is required"})
+		return
+	}
+
+	var request models.RestockRequest
+	if err := db.DB.First(&request, "id = ?", requestID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Restock request not found"})
+		return
+	}
+
+	if request.Status != "pending" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending restock requests can be rejected"})
+		return
+	}
+
+	now := time.Now()
+	if err := db.DB.Model(&request).Updates(map[string]interface{}{
+		"status":       "rejected",
+		"reviewed_by":  userID,
+		"review_notes": body.ReviewNotes,
+		"reviewed_at":  &now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject restock request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Restock request rejected"})
+}
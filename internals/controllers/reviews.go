@@ -0,0 +1,427 @@
+// product reviews controller
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// reviewSorts are the orderings of a product's reviews.
+var reviewSorts = map[string]string{
+	"newest":  "created_at DESC",
+	"highest": "rating DESC, created_at DESC",
+	"lowest":  "rating ASC, created_at DESC",
+}
+
+// reviewerName shows reviewers by first name and last initial.
+func reviewerName(user models.User) string {
+	if user.AnonymisedAt != nil {
+		return "Deleted user"
+	}
+	parts := strings.Fields(user.Name)
+	if len(parts) == 0 {
+		return "Customer"
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return parts[0] + " " + string([]rune(parts[len(parts)-1])[0]) + "."
+}
+
+// reviewSummary is the public view of a review.
+func reviewSummary(review models.Review) gin.H {
+	return gin.H{
+		"id":               review.ID,
+		"productId":        review.ProductID,
+		"rating":           review.Rating,
+		"title":            review.Title,
+		"body":             review.Body,
+		"reviewer":         reviewerName(review.User),
+		"verifiedPurchase": true,
+		"createdAt":        review.CreatedAt,
+		"updatedAt":        review.UpdatedAt,
+	}
+}
+
+// reviewDetails adds what the author and moderators see to reviewSummary.
+func reviewDetails(review models.Review) gin.H {
+	details := reviewSummary(review)
+	details["userId"] = review.UserID
+	details["orderId"] = review.OrderID
+	details["status"] = review.Status
+	details["moderatedAt"] = review.ModeratedAt
+	details["moderationNote"] = review.ModerationNote
+	if review.Product.ID != "" {
+		details["productName"] = review.Product.Name
+	}
+	return details
+}
+
+// refreshProductRating recomputes products' rating (the mean of approved
+// reviews, to one decimal place) and review count.
+func refreshProductRating(tx *gorm.DB, productIDs ...string) error {
+	return tx.Exec(`UPDATE products SET
+			rating = coalesce((SELECT round(avg(r.rating)::numeric, 1) FROM reviews r
+				WHERE r.product_id = products.id AND r.status = 'approved' AND r.deleted_at IS NULL), 0),
+			reviews = (SELECT count(*) FROM reviews r
+				WHERE r.product_id = products.id AND r.status = 'approved' AND r.deleted_at IS NULL)
+		WHERE id IN ?`, productIDs).Error
+}
+
+// GetProductReviews returns one page of a product's approved reviews with a
+// summary of all of them.
+func GetProductReviews(c *gin.Context) {
+	sortName := c.DefaultQuery("sort", "newest")
+	order, ok := reviewSorts[sortName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of newest, highest, lowest"})
+		return
+	}
+
+	var product models.Product
+	if err := db.DB.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	limit := pageLimit(c)
+	page := pageNumber(c)
+
+	query := db.DB.Model(&models.Review{}).Where("product_id = ? AND status = ?", product.ID, "approved")
+
+	var distribution []struct {
+		Rating int
+		Count  int64
+	}
+	if err := query.Session(&gorm.Session{}).Select("rating, count(*) AS count").Group("rating").
+		Scan(&distribution).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarise reviews"})
+		return
+	}
+
+	counts := gin.H{"1": 0, "2": 0, "3": 0, "4": 0, "5": 0}
+	var total int64
+	for _, row := range distribution {
+		counts[strconv.Itoa(row.Rating)] = row.Count
+		total += row.Count
+	}
+
+	var reviews []models.Review
+	if err := query.Session(&gorm.Session{}).Preload("User").
+		Order(order).
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	results := []gin.H{}
+	for _, review := range reviews {
+		results = append(results, reviewSummary(review))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews": results,
+		"summary": gin.H{
+			"rating":       product.Rating,
+			"count":        product.Reviews,
+			"distribution": counts,
+		},
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+			"sort":  sortName,
+		},
+	})
+}
+
+type reviewInput struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" binding:"max=100"`
+	Body   string `json:"body" binding:"max=2000"`
+}
+
+// CreateReview lets a customer review a product from one of their completed
+// orders. Reviews wait for moderation before they are shown.
+func CreateReview(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body reviewInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var product models.Product
+	if err := db.DB.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	var order models.Order
+	if err := db.DB.
+		Where("user_id = ? AND order_status = ?", userID, "completed").
+		Where("EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND oi.product_id = ? AND oi.deleted_at IS NULL)", product.ID).
+		Order("completed_at DESC").
+		First(&order).Error; err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only review products from your completed orders"})
+		return
+	}
+
+	var existing int64
+	db.DB.Model(&models.Review{}).Where("product_id = ? AND user_id = ?", product.ID, userID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this product; edit your review instead"})
+		return
+	}
+
+	review := models.Review{
+		ProductID: product.ID,
+		UserID:    userID.(string),
+		OrderID:   order.ID,
+		Rating:    body.Rating,
+		Title:     strings.TrimSpace(body.Title),
+		Body:      strings.TrimSpace(body.Body),
+		Status:    "pending",
+	}
+
+	if err := db.DB.Create(&review).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+		return
+	}
+
+	db.DB.Preload("User").Preload("Product").First(&review, "id = ?", review.ID)
+	c.JSON(http.StatusCreated, reviewDetails(review))
+}
+
+// GetMyReviews lists the caller's reviews, including any awaiting moderation
+// or rejected.
+func GetMyReviews(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var reviews []models.Review
+	if err := db.DB.Preload("User").Preload("Product").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	results := []gin.H{}
+	for _, review := range reviews {
+		results = append(results, reviewDetails(review))
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// UpdateMyReview replaces the caller's review. The edited review goes back
+// to moderation.
+func UpdateMyReview(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body reviewInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var review models.Review
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&review).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Model(&review).Updates(map[string]interface{}{
+		"rating":          body.Rating,
+		"title":           strings.TrimSpace(body.Title),
+		"body":            strings.TrimSpace(body.Body),
+		"status":          "pending",
+		"moderated_by":    nil,
+		"moderated_at":    nil,
+		"moderation_note": "",
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+
+	if err := refreshProductRating(tx, review.ProductID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product rating"})
+		return
+	}
+
+	tx.Commit()
+
+	db.DB.Preload("User").Preload("Product").First(&review, "id = ?", review.ID)
+	c.JSON(http.StatusOK, reviewDetails(review))
+}
+
+// DeleteMyReview removes the caller's review.
+func DeleteMyReview(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var review models.Review
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&review).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Delete(&review).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete review"})
+		return
+	}
+
+	if err := refreshProductRating(tx, review.ProductID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product rating"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted successfully"})
+}
+
+// GetReviewsForModeration lists reviews for moderators, pending ones by
+// default. Filter with status and productId.
+func GetReviewsForModeration(c *gin.Context) {
+	status := c.DefaultQuery("status", "pending")
+	productID := c.Query("productId")
+
+	limit := pageLimit(c)
+	page := pageNumber(c)
+
+	query := db.DB.Model(&models.Review{})
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count reviews"})
+		return
+	}
+
+	var reviews []models.Review
+	if err := query.Preload("User").Preload("Product").
+		Order("created_at ASC").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	results := []gin.H{}
+	for _, review := range reviews {
+		results = append(results, reviewDetails(review))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reviews": results,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+// moderateReview sets a review's status and recomputes the product's rating.
+func moderateReview(c *gin.Context, status, note string) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var review models.Review
+	if err := db.DB.First(&review, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+
+	moderatorID := userID.(string)
+	tx := db.DB.Begin()
+
+	if err := tx.Model(&review).Updates(map[string]interface{}{
+		"status":          status,
+		"moderated_by":    moderatorID,
+		"moderated_at":    time.Now(),
+		"moderation_note": note,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate review"})
+		return
+	}
+
+	if err := refreshProductRating(tx, review.ProductID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product rating"})
+		return
+	}
+
+	tx.Commit()
+
+	db.DB.Preload("User").Preload("Product").First(&review, "id = ?", review.ID)
+	c.JSON(http.StatusOK, reviewDetails(review))
+}
+
+// ApproveReview publishes a review.
+func ApproveReview(c *gin.Context) {
+	moderateReview(c, "approved", "")
+}
+
+// RejectReview hides a review, with an optional note for its author. A
+// previously approved review stops counting towards the product's rating.
+func RejectReview(c *gin.Context) {
+	var body struct {
+		Note string `json:"note" binding:"max=255"`
+	}
+
+	// The note is optional, so an empty body is fine
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+	}
+
+	moderateReview(c, "rejected", strings.TrimSpace(body.Note))
+}
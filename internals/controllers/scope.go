@@ -0,0 +1,59 @@
+// branch scoping helpers for staff routes
+package controllers
+
+import (
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// hasPermission reports whether the caller holds permission, for routes that
+// need a second permission for some of what they do. API keys are checked
+// against their scopes, as in middlewares.RequirePermission.
+func hasPermission(c *gin.Context, permission string) bool {
+	if scopes, isAPIKey := c.Get("apiKeyScopes"); isAPIKey {
+		for _, scope := range scopes.([]string) {
+			if scope == permission {
+				return true
+			}
+		}
+		return false
+	}
+	return permissions.Has(c.GetString("userRole"), permission)
+}
+
+// scopedBranchIDs returns the branches the caller is limited to, as loaded by
+// middlewares.RequirePermission, or nil when the caller can see every branch.
+func scopedBranchIDs(c *gin.Context) []string {
+	scope, exists := c.Get("branchScope")
+	if !exists {
+		return nil
+	}
+	branchIDs, _ := scope.([]string)
+	if branchIDs == nil {
+		branchIDs = []string{}
+	}
+	return branchIDs
+}
+
+// canAccessBranch reports whether the caller may see or change branchID.
+func canAccessBranch(c *gin.Context, branchID string) bool {
+	branchIDs := scopedBranchIDs(c)
+	if branchIDs == nil {
+		return true
+	}
+	for _, id := range branchIDs {
+		if id == branchID {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeToBranches restricts query to the caller's branches on column.
+func scopeToBranches(c *gin.Context, query *gorm.DB, column string) *gorm.DB {
+	if branchIDs := scopedBranchIDs(c); branchIDs != nil {
+		return query.Where(column+" IN ?", branchIDs)
+	}
+	return query
+}
@@ -0,0 +1,149 @@
+// search controller
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxSearchTerms = 8
+	// Minimum pg_trgm word similarity for a misspelt term to count as a match;
+	// "coka" against "coke" scores 0.6, "pasion" against "passion" 0.86
+	searchSimilarityThreshold = 0.5
+)
+
+var searchTermPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// searchTerms splits a search query into lower-case words, dropping
+// punctuation so that the words are safe to use in a tsquery.
+func searchTerms(query string) []string {
+	terms := searchTermPattern.FindAllString(strings.ToLower(query), -1)
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+type productSearchResult struct {
+	models.Product
+	Rank                 float64
+	NameHighlight        string
+	DescriptionHighlight string
+}
+
+// SearchProducts searches product names, brands, variants (names, volumes,
+// SKUs and barcodes), tags and descriptions. Every term must match, either as a word prefix or by trigram
+// similarity so that typos are tolerated. Results are ranked by relevance, and
+// exact and prefix matches are wrapped in <mark> in the highlights. Pass
+// branchId to only return products in stock at that branch.
+func SearchProducts(c *gin.Context) {
+	terms := searchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query q is required"})
+		return
+	}
+
+	limit := pageLimit(c)
+	page := pageNumber(c)
+
+	prefixes := []string{}
+	for _, term := range terms {
+		prefixes = append(prefixes, term+":*")
+	}
+	anyTerm := strings.Join(prefixes, " | ")
+	text := strings.Join(terms, " ")
+
+	// The trigram index is only used through the <% operator, which takes its
+	// threshold from a setting, so it is set for this transaction only
+	tx := db.DB.Begin()
+	if err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)",
+		fmt.Sprint(searchSimilarityThreshold)).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search products"})
+		return
+	}
+
+	query := tx.Model(&models.Product{})
+	for _, term := range terms {
+		query = query.Where(
+			fmt.Sprintf("(%s @@ to_tsquery('simple', ?) OR ? <%% %s)",
+				models.ProductSearchVector, models.ProductSearchText),
+			term+":*", term,
+		)
+	}
+
+	if branchID := c.Query("branchId"); branchID != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM branch_inventories bi
+			WHERE bi.product_id = products.id AND bi.branch_id = ? AND bi.quantity > 0 AND bi.deleted_at IS NULL)`, branchID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search products"})
+		return
+	}
+
+	var results []productSearchResult
+	if err := query.
+		Select(fmt.Sprintf(`products.*,
+			ts_rank(%s, to_tsquery('simple', ?)) + word_similarity(?, %s) AS rank,
+			ts_headline('simple', name, to_tsquery('simple', ?), 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS name_highlight,
+			ts_headline('simple', coalesce(description, ''), to_tsquery('simple', ?), 'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10') AS description_highlight`,
+			models.ProductSearchVector, models.ProductSearchText),
+			anyTerm, text, anyTerm, anyTerm).
+		Order("rank DESC, id").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Scan(&results).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search products"})
+		return
+	}
+	tx.Commit()
+
+	// Scan does not preload associations, so fetch categories and tags separately
+	productIDs := []string{}
+	for _, result := range results {
+		productIDs = append(productIDs, result.ID)
+	}
+	var products []models.Product
+	db.DB.Preload("Category").Preload("Tags").Where("id IN ?", productIDs).Find(&products)
+	byID := map[string]models.Product{}
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	response := []gin.H{}
+	for _, result := range results {
+		product := result.Product
+		if loaded, ok := byID[result.ID]; ok {
+			product = loaded
+		}
+		response = append(response, gin.H{
+			"product": product,
+			"rank":    result.Rank,
+			"highlight": gin.H{
+				"name":        result.NameHighlight,
+				"description": result.DescriptionHighlight,
+			},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   c.Query("q"),
+		"results": response,
+		"pagination": gin.H{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
@@ -0,0 +1,283 @@
+// sessions controller
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultRefreshCookieName = "refresh_token"
+
+func refreshCookieName() string {
+	if name := os.Getenv("REFRESH_COOKIE_NAME"); name != "" {
+		return name
+	}
+	return defaultRefreshCookieName
+}
+
+// setRefreshCookie scopes the refresh token to the auth routes so it is not
+// sent with every API call.
+func setRefreshCookie(c *gin.Context, token string) {
+	secure := os.Getenv("COOKIE_SECURE") == "true"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(refreshCookieName(), token, int(utils.RefreshTokenTTL.Seconds()), "/api/v1/auth", "", secure, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	cookieName := os.Getenv("JWT_COOKIE_NAME")
+	if cookieName == "" {
+		cookieName = defaultAuthCookieName
+	}
+
+	c.SetCookie(cookieName, "", -1, "/", "", false, true)
+	c.SetCookie(refreshCookieName(), "", -1, "/api/v1/auth", "", false, true)
+}
+
+// startSession records a new session for user on this device, sets the auth
+// cookies and returns the access and refresh tokens.
+func startSession(c *gin.Context, user models.User) (string, string, error) {
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		UserAgent:        truncate(c.Request.UserAgent(), 255),
+		IPAddress:        c.ClientIP(),
+		ExpiresAt:        now.Add(utils.RefreshTokenTTL),
+		LastUsedAt:       now,
+	}
+
+	if err := db.DB.Create(&session).Error; err != nil {
+		return "", "", err
+	}
+
+	token := utils.GenerateJWT(user.ID, user.Role, session.ID)
+	setAuthCookie(c, token)
+	setRefreshCookie(c, refreshToken)
+
+	recordActivity(c, user.ID, "login", "")
+
+	return token, refreshToken, nil
+}
+
+// revokeUserSessions revokes every active session of userID except keepID
+// (pass "" to revoke them all).
+func revokeUserSessions(userID, keepID string) error {
+	query := db.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepID != "" {
+		query = query.Where("id <> ?", keepID)
+	}
+	return query.Update("revoked_at", time.Now()).Error
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// RefreshSession exchanges a refresh token (from the body or the refresh
+// cookie) for a new access token and a rotated refresh token. Presenting a
+// refresh token that has already been rotated out revokes the session, since
+// it means the token was copied.
+func RefreshSession(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	refreshToken := body.RefreshToken
+	if refreshToken == "" {
+		if cookie, err := c.Cookie(refreshCookieName()); err == nil {
+			refreshToken = cookie
+		}
+	}
+
+	if refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token required"})
+		return
+	}
+
+	tokenHash := utils.HashToken(refreshToken)
+
+	var session models.Session
+	if err := db.DB.Where("refresh_token_hash = ?", tokenHash).First(&session).Error; err != nil {
+		// A rotated-out token being replayed: shut the session down
+		var reused models.Session
+		if err := db.DB.Where("previous_token_hash = ? AND revoked_at IS NULL", tokenHash).First(&reused).Error; err == nil {
+			db.DB.Model(&reused).Update("revoked_at", time.Now())
+			utils.Logger.WithFields(map[string]interface{}{
+				"session_id": reused.ID,
+				"user_id":    reused.UserID,
+			}).Warn("Refresh token reuse detected, session revoked")
+		}
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.Where("id = ?", session.UserID).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.DisabledAt != nil {
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "This account has been disabled"})
+		return
+	}
+
+	newRefreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+
+	// Only rotate if nobody else rotated this token in the meantime
+	result := db.DB.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, tokenHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  utils.HashToken(newRefreshToken),
+			"previous_token_hash": tokenHash,
+			"last_used_at":        time.Now(),
+			"ip_address":          c.ClientIP(),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		return
+	}
+
+	// Role changes take effect here, since the access token is reissued from the user record
+	token := utils.GenerateJWT(user.ID, user.Role, session.ID)
+	setAuthCookie(c, token)
+	setRefreshCookie(c, newRefreshToken)
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":        token,
+		"refreshToken": newRefreshToken,
+		"expiresIn":    int(utils.AccessTokenTTL.Seconds()),
+	})
+}
+
+func GetSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var sessions []models.Session
+	if err := db.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	currentID := c.GetString("sessionID")
+	result := []gin.H{}
+	for _, session := range sessions {
+		result = append(result, gin.H{
+			"id":         session.ID,
+			"userAgent":  session.UserAgent,
+			"ipAddress":  session.IPAddress,
+			"createdAt":  session.CreatedAt,
+			"lastUsedAt": session.LastUsedAt,
+			"expiresAt":  session.ExpiresAt,
+			"current":    session.ID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID := c.Param("id")
+
+	result := db.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if sessionID == c.GetString("sessionID") {
+		clearAuthCookies(c)
+	}
+
+	recordActivity(c, c.GetString("userID"), "session_revoked", "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeAllSessions signs the user out everywhere. With ?keepCurrent=true the
+// session making the request stays signed in.
+func RevokeAllSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	keepID := ""
+	if c.Query("keepCurrent") == "true" {
+		keepID = c.GetString("sessionID")
+	}
+
+	if err := revokeUserSessions(userID.(string), keepID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	if keepID == "" {
+		clearAuthCookies(c)
+	}
+
+	recordActivity(c, c.GetString("userID"), "sessions_revoked", "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked successfully"})
+}
+
+// GetJWKS publishes the keys that verify access tokens. Caches may keep it
+// for a few minutes, so new keys should be published before signing with them.
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.JWKS())
+}
@@ -0,0 +1,159 @@
+// suppliers controller
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/gin-gonic/gin"
+)
+
+func CreateSupplier(c *gin.Context) {
+	var body struct {
+		Name        string `json:"name" binding:"required"`
+		ContactName string `json:"contactName"`
+		Email       string `json:"email" binding:"omitempty,email"`
+		Phone       string `json:"phone" binding:"required"`
+		Address     string `json:"address"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var existingSupplier models.Supplier
+	if err := db.DB.Where("name = ?", body.Name).First(&existingSupplier).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Supplier already exists"})
+		return
+	}
+
+	supplier := models.Supplier{
+		Name:        body.Name,
+		ContactName: body.ContactName,
+		Email:       body.Email,
+		Phone:       body.Phone,
+		Address:     body.Address,
+		Status:      "active",
+	}
+
+	if err := db.DB.Create(&supplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create supplier"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, supplier)
+}
+
+func GetAllSuppliers(c *gin.Context) {
+	status := c.Query("status")
+
+	query := db.DB.Model(&models.Supplier{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var suppliers []models.Supplier
+	if err := query.Order("name ASC").Find(&suppliers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch suppliers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppliers)
+}
+
+func GetSupplier(c *gin.Context) {
+	supplierID := c.Param("id")
+
+	var supplier models.Supplier
+	if err := db.DB.First(&supplier, "id = ?", supplierID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, supplier)
+}
+
+func UpdateSupplier(c *gin.Context) {
+	supplierID := c.Param("id")
+
+	var supplier models.Supplier
+	if err := db.DB.First(&supplier, "id = ?", supplierID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	var body struct {
+		Name        *string `json:"name" binding:"omitempty,min=1"`
+		ContactName *string `json:"contactName"`
+		Email       *string `json:"email" binding:"omitempty,email"`
+		Phone       *string `json:"phone" binding:"omitempty,min=1"`
+		Address     *string `json:"address"`
+		Status      *string `json:"status" binding:"omitempty,oneof=active inactive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if body.Name != nil {
+		var existingSupplier models.Supplier
+		if err := db.DB.Where("name = ? AND id <> ?", *body.Name, supplier.ID).First(&existingSupplier).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Supplier already exists"})
+			return
+		}
+		updates["name"] = *body.Name
+	}
+	if body.ContactName != nil {
+		updates["contact_name"] = *body.ContactName
+	}
+	if body.Email != nil {
+		updates["email"] = *body.Email
+	}
+	if body.Phone != nil {
+		updates["phone"] = *body.Phone
+	}
+	if body.Address != nil {
+		updates["address"] = *body.Address
+	}
+	if body.Status != nil {
+		updates["status"] = *body.Status
+	}
+
+	if len(updates) > 0 {
+		if err := db.DB.Model(&supplier).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, supplier)
+}
+
+func DeleteSupplier(c *gin.Context) {
+	supplierID := c.Param("id")
+
+	var supplier models.Supplier
+	if err := db.DB.First(&supplier, "id = ?", supplierID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+		return
+	}
+
+	// Suppliers with purchase orders are kept for the audit trail; deactivate them instead
+	var orderCount int64
+	db.DB.Model(&models.PurchaseOrder{}).Where("supplier_id = ?", supplierID).Count(&orderCount)
+	if orderCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete supplier with existing purchase orders"})
+		return
+	}
+
+	if err := db.DB.Delete(&supplier).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete supplier"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Supplier deleted successfully"})
+}
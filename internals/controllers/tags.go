@@ -0,0 +1,259 @@
+// tags controller
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const maxProductTags = 20
+
+// findOrCreateTags returns the tags with the given names, creating any that
+// do not exist yet. Names that differ only in case or punctuation share a tag.
+func findOrCreateTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		slug := utils.Slugify(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		var tag models.Tag
+		if err := tx.Where(models.Tag{Slug: slug}).Attrs(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// refreshTagNames rewrites the tag_names copy that product search indexes,
+// after the tags of the given products have changed.
+func refreshTagNames(tx *gorm.DB, productIDs []string) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+	return tx.Exec(`UPDATE products SET tag_names = coalesce((
+			SELECT string_agg(t.name, ' ' ORDER BY t.name) FROM product_tags pt
+			JOIN tags t ON t.id = pt.tag_id AND t.deleted_at IS NULL
+			WHERE pt.product_id = products.id), '')
+		WHERE id IN ?`, productIDs).Error
+}
+
+// setProductTags replaces the tags on product with the named ones.
+func setProductTags(tx *gorm.DB, product *models.Product, names []string) error {
+	tags, err := findOrCreateTags(tx, names)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(product).Association("Tags").Replace(tags); err != nil {
+		return err
+	}
+	product.Tags = tags
+	return refreshTagNames(tx, []string{product.ID})
+}
+
+// tagNames lists the names of tags, for the response shapes that return
+// tags as plain strings.
+func tagNames(tags []models.Tag) []string {
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+// GetTags lists every tag with the number of products carrying it.
+func GetTags(c *gin.Context) {
+	var rows []struct {
+		ID           string
+		Name         string
+		Slug         string
+		ProductCount int64
+	}
+	if err := db.DB.Model(&models.Tag{}).
+		Select(`tags.id, tags.name, tags.slug, (SELECT COUNT(*) FROM product_tags pt
+			JOIN products p ON p.id = pt.product_id AND p.deleted_at IS NULL
+			WHERE pt.tag_id = tags.id) AS product_count`).
+		Order("tags.name").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, row := range rows {
+		result = append(result, gin.H{
+			"id":           row.ID,
+			"name":         row.Name,
+			"slug":         row.Slug,
+			"productCount": row.ProductCount,
+		})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func CreateTag(c *gin.Context) {
+	var body struct {
+		Name string `json:"name" binding:"required,max=50"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tag := models.Tag{Name: body.Name, Slug: utils.Slugify(body.Name)}
+	if tag.Slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name must contain letters or digits"})
+		return
+	}
+
+	var existing int64
+	db.DB.Model(&models.Tag{}).Where("slug = ?", tag.Slug).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+		return
+	}
+
+	if err := db.DB.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag renames a tag. Products keep the tag; only its name and slug change.
+func UpdateTag(c *gin.Context) {
+	var body struct {
+		Name string `json:"name" binding:"required,max=50"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var tag models.Tag
+	if err := db.DB.First(&tag, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	slug := utils.Slugify(body.Name)
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name must contain letters or digits"})
+		return
+	}
+
+	var existing int64
+	db.DB.Model(&models.Tag{}).Where("slug = ? AND id <> ?", slug, tag.ID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+		return
+	}
+
+	var productIDs []string
+	db.DB.Table("product_tags").Where("tag_id = ?", tag.ID).Pluck("product_id", &productIDs)
+
+	tx := db.DB.Begin()
+
+	if err := tx.Model(&tag).Updates(map[string]interface{}{"name": body.Name, "slug": slug}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+		return
+	}
+
+	if err := refreshTagNames(tx, productIDs); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tagged products"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag removes a tag from every product and then deletes it.
+func DeleteTag(c *gin.Context) {
+	var tag models.Tag
+	if err := db.DB.First(&tag, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	var productIDs []string
+	db.DB.Table("product_tags").Where("tag_id = ?", tag.ID).Pluck("product_id", &productIDs)
+
+	tx := db.DB.Begin()
+
+	if err := tx.Exec("DELETE FROM product_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to untag products"})
+		return
+	}
+
+	if err := tx.Delete(&tag).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+
+	if err := refreshTagNames(tx, productIDs); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tagged products"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// SetProductTags replaces a product's tags. Unknown tag names are created.
+func SetProductTags(c *gin.Context) {
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if len(body.Tags) > maxProductTags {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A product can have at most 20 tags"})
+		return
+	}
+
+	var product models.Product
+	if err := db.DB.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	if err := setProductTags(tx, &product, body.Tags); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product tags"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"productId": product.ID,
+		"tags":      product.Tags,
+	})
+}
@@ -0,0 +1,416 @@
+// two-factor authentication controller
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	totpIssuer            = "Drinx Retailers"
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+)
+
+// completeLogin finishes a sign-in whose first factor has been checked. Users
+// with 2FA enabled get a short-lived challenge token to exchange, along with
+// a TOTP or recovery code, at /auth/login/2fa; everyone else gets a session.
+func completeLogin(c *gin.Context, user models.User, message string) {
+	if rejectBlockedLogin(c, user) {
+		return
+	}
+
+	if user.TwoFactorEnabledAt != nil {
+		challenge, err := issueUserToken(user.ID, "two_factor_login", user.Email, twoFactorChallengeTTL, false)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":           "Two-factor authentication required",
+			"twoFactorRequired": true,
+			"challengeToken":    challenge,
+			"expiresIn":         int(twoFactorChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	token, refreshToken, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionResponse(message, user, token, refreshToken))
+}
+
+// rejectBlockedLogin responds with 403 and returns true if an admin has
+// disabled the account or required a password reset.
+func rejectBlockedLogin(c *gin.Context, user models.User) bool {
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account has been disabled"})
+		return true
+	}
+	if user.PasswordResetRequired {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                 "A password reset is required; use the link sent to your email or request a new one",
+			"passwordResetRequired": true,
+		})
+		return true
+	}
+	return false
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code for user, consuming it so it cannot be used again.
+func checkSecondFactor(tx *gorm.DB, user *models.User, code, recoveryCode string) bool {
+	if code != "" {
+		step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+		if !ok || step <= user.TOTPLastStep {
+			return false
+		}
+		return tx.Model(user).Update("totp_last_step", step).Error == nil
+	}
+
+	if recoveryCode != "" {
+		result := tx.Model(&models.TwoFactorRecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		return result.Error == nil && result.RowsAffected == 1
+	}
+
+	return false
+}
+
+// replaceRecoveryCodes invalidates any existing recovery codes for userID and
+// returns a fresh set. The plain codes are only ever shown this once.
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.TwoFactorRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := []string{}
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		recovery := models.TwoFactorRecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(utils.NormalizeRecoveryCode(code)),
+		}
+		if err := tx.Create(&recovery).Error; err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// VerifyTwoFactorLogin is the second login step for users with 2FA enabled.
+// A challenge allows maxCodeAttempts wrong codes before the user has to sign
+// in with their password again.
+func VerifyTwoFactorLogin(c *gin.Context) {
+	var body struct {
+		ChallengeToken string `json:"challengeToken" binding:"required"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recoveryCode"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil || (body.Code == "" && body.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challengeToken and either code or recoveryCode are required"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	var challenge models.UserToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("purpose = ? AND token_hash = ? AND used_at IS NULL AND expires_at > ?", "two_factor_login", utils.HashToken(body.ChallengeToken), time.Now()).
+		First(&challenge).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or has expired, please log in again"})
+		return
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&user, "id = ?", challenge.UserID).Error; err != nil || user.TwoFactorEnabledAt == nil {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or has expired, please log in again"})
+		return
+	}
+
+	if !checkSecondFactor(tx, &user, body.Code, body.RecoveryCode) {
+		updates := map[string]interface{}{"attempts": challenge.Attempts + 1}
+		if challenge.Attempts+1 >= maxCodeAttempts {
+			updates["used_at"] = time.Now()
+		}
+		tx.Model(&challenge).Updates(updates)
+		tx.Commit()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	if err := tx.Model(&challenge).Update("used_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete login"})
+		return
+	}
+
+	tx.Commit()
+
+	if rejectBlockedLogin(c, user) {
+		return
+	}
+
+	token, refreshToken, err := startSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessionResponse("Login successful", user, token, refreshToken))
+}
+
+// SetupTwoFactor generates a new TOTP secret for the caller. It only takes
+// effect once confirmed with a code through EnableTwoFactor.
+func SetupTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TwoFactorEnabledAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	if err := db.DB.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor setup"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":          secret,
+		"provisioningUri": utils.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor confirms setup with a code from the authenticator app and
+// returns the recovery codes.
+func EnableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TwoFactorEnabledAt != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start two-factor setup first"})
+		return
+	}
+
+	if !checkSecondFactor(tx, &user, body.Code, "") {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	if err := tx.Model(&user).Update("two_factor_enabled_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+
+	tx.Commit()
+
+	recordActivity(c, user.ID, "two_factor_enabled", "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Two-factor authentication enabled. Store these recovery codes somewhere safe; they will not be shown again.",
+		"recoveryCodes": codes,
+	})
+}
+
+// DisableTwoFactor turns 2FA off after re-checking the password and a code.
+// Admins cannot turn it off while the 2FA policy applies to them.
+func DisableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Password     string `json:"password" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TwoFactorEnabledAt == nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if permissions.RequiresTwoFactor(user.Role) {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role and cannot be disabled"})
+		return
+	}
+
+	if !utils.CheckPassword(body.Password, user.Password) || !checkSecondFactor(tx, &user, body.Code, body.RecoveryCode) {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password or two-factor code"})
+		return
+	}
+
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":           "",
+		"totp_last_step":        0,
+		"two_factor_enabled_at": nil,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.TwoFactorRecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove recovery codes"})
+		return
+	}
+
+	tx.Commit()
+
+	recordActivity(c, user.ID, "two_factor_disabled", "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes, for example
+// after most have been used.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.TwoFactorEnabledAt == nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if !checkSecondFactor(tx, &user, body.Code, "") {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+
+	tx.Commit()
+
+	recordActivity(c, user.ID, "recovery_codes_regenerated", "")
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
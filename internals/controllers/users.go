@@ -0,0 +1,404 @@
+// users controller
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	invitationTTL    = 7 * 24 * time.Hour
+	defaultUserLimit = 50
+	maxUserLimit     = 200
+)
+
+// userSummary is how user accounts are shown to admins.
+func userSummary(user models.User) gin.H {
+	branchIDs := []string{}
+	for _, branch := range user.Branches {
+		branchIDs = append(branchIDs, branch.ID)
+	}
+
+	return gin.H{
+		"id":                    user.ID,
+		"name":                  user.Name,
+		"email":                 user.Email,
+		"phone":                 user.Phone,
+		"role":                  user.Role,
+		"branchIds":             branchIDs,
+		"emailVerified":         user.EmailVerifiedAt != nil,
+		"phoneVerified":         user.PhoneVerifiedAt != nil,
+		"twoFactorEnabled":      user.TwoFactorEnabledAt != nil,
+		"disabled":              user.DisabledAt != nil,
+		"disabledAt":            user.DisabledAt,
+		"passwordResetRequired": user.PasswordResetRequired,
+		"deleted":               user.AnonymisedAt != nil,
+		"createdAt":             user.CreatedAt,
+	}
+}
+
+// isLastActiveAdmin reports whether user is the only admin who can still log
+// in, so that they are not demoted or disabled by mistake.
+func isLastActiveAdmin(user models.User) bool {
+	if user.Role != permissions.RoleAdmin || user.DisabledAt != nil {
+		return false
+	}
+
+	var others int64
+	db.DB.Model(&models.User{}).
+		Where("role = ? AND disabled_at IS NULL AND id <> ?", permissions.RoleAdmin, user.ID).
+		Count(&others)
+	return others == 0
+}
+
+// findBranches loads the branches for a branch-scoped role, which must have
+// at least one.
+func findBranches(role string, branchIDs []string) ([]models.Branch, string) {
+	branches := []models.Branch{}
+	if !permissions.IsBranchScoped(role) {
+		return branches, ""
+	}
+	if len(branchIDs) == 0 {
+		return nil, "Branch staff must be assigned at least one branch"
+	}
+	if err := db.DB.Where("id IN ?", branchIDs).Find(&branches).Error; err != nil {
+		return nil, "Failed to fetch branches"
+	}
+	if len(branches) != len(branchIDs) {
+		return nil, "One or more branches not found"
+	}
+	return branches, ""
+}
+
+// UpdateUserAccess sets a user's role and the branches they work at.
+// Cashiers and branch managers must be assigned at least one branch; other
+// roles have their branch assignments cleared. Access tokens carry the role,
+// so a role change signs the user out.
+func UpdateUserAccess(c *gin.Context) {
+	targetID := c.Param("id")
+
+	var body struct {
+		Role      string   `json:"role" binding:"required"`
+		BranchIDs []string `json:"branchIds"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if !permissions.IsValidRole(body.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.Where("id = ?", targetID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if body.Role != permissions.RoleAdmin && isLastActiveAdmin(user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change the role of the last active admin"})
+		return
+	}
+
+	branches, errMessage := findBranches(body.Role, body.BranchIDs)
+	if errMessage != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	roleChanged := user.Role != body.Role
+	tx := db.DB.Begin()
+
+	if err := tx.Model(&user).Update("role", body.Role).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	if err := tx.Model(&user).Association("Branches").Replace(branches); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update branch assignments"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user access"})
+		return
+	}
+
+	if roleChanged {
+		if err := revokeUserSessions(user.ID, ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Role updated but sessions could not be revoked"})
+			return
+		}
+	}
+
+	branchIDs := []string{}
+	for _, branch := range branches {
+		branchIDs = append(branchIDs, branch.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "User access updated successfully",
+		"id":          user.ID,
+		"role":        user.Role,
+		"branchIds":   branchIDs,
+		"permissions": permissions.ForRole(user.Role),
+	})
+}
+
+// GetUsers lists user accounts, newest first. q searches name, email and
+// phone; role, status (active or disabled) and branchId narrow the list.
+func GetUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultUserLimit)))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > maxUserLimit {
+		limit = defaultUserLimit
+	}
+
+	query := db.DB.Model(&models.User{})
+
+	if q := c.Query("q"); q != "" {
+		pattern := "%" + q + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ? OR phone ILIKE ?", pattern, pattern, pattern)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	switch c.Query("status") {
+	case "active":
+		query = query.Where("disabled_at IS NULL")
+	case "disabled":
+		query = query.Where("disabled_at IS NOT NULL")
+	}
+	if branchID := c.Query("branchId"); branchID != "" {
+		query = query.Where("id IN (?)", db.DB.Table("user_branches").Select("user_id").Where("branch_id = ?", branchID))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count users"})
+		return
+	}
+
+	var users []models.User
+	if err := query.Preload("Branches").
+		Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, user := range users {
+		result = append(result, userSummary(user))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": result,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+func GetUser(c *gin.Context) {
+	targetID := c.Param("id")
+
+	var user models.User
+	if err := db.DB.Preload("Branches").Where("id = ?", targetID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, userSummary(user))
+}
+
+// InviteUser creates a staff account and emails the new user a link to set
+// their password. The account has no usable password until they do.
+func InviteUser(c *gin.Context) {
+	var body struct {
+		Name      string   `json:"name" binding:"required"`
+		Email     string   `json:"email" binding:"required,email"`
+		Phone     string   `json:"phone" binding:"required"`
+		Role      string   `json:"role" binding:"required"`
+		BranchIDs []string `json:"branchIds"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if !permissions.IsValidRole(body.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+		return
+	}
+
+	phone, err := utils.NormalizePhone(body.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existingUser models.User
+	if err := db.DB.Where("email = ? OR phone = ?", body.Email, phone).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email or phone already exists"})
+		return
+	}
+
+	branches, errMessage := findBranches(body.Role, body.BranchIDs)
+	if errMessage != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	placeholder, err := utils.GenerateRefreshToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	user := models.User{
+		Name:     body.Name,
+		Email:    body.Email,
+		Phone:    phone,
+		Password: utils.HashPassword(placeholder),
+		Role:     body.Role,
+		Branches: branches,
+	}
+
+	if err := db.DB.Create(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// The invitation is a password reset link that lasts long enough to be acted on
+	raw, err := issueUserToken(user.ID, "password_reset", user.Email, invitationTTL, false)
+	if err == nil {
+		err = services.NewNotifier().Send(services.Notification{
+			Channel: "email",
+			To:      user.Email,
+			Subject: "You have been invited to Drinx Retailers",
+			Body: fmt.Sprintf("Hi %s,\n\nAn account has been created for you. Set your password within 7 days to sign in:\n%s/accept-invite?token=%s",
+				user.Name, appURL(), raw),
+		})
+	}
+
+	response := userSummary(user)
+	response["invitationSent"] = err == nil
+	if err != nil {
+		utils.Logger.WithError(err).Error("Failed to send invitation")
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// DisableUser stops a user from logging in and ends their sessions.
+func DisableUser(c *gin.Context) {
+	targetID := c.Param("id")
+
+	if targetID == c.GetString("userID") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot disable your own account"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.Where("id = ?", targetID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User is already disabled"})
+		return
+	}
+	if isLastActiveAdmin(user) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot disable the last active admin"})
+		return
+	}
+
+	if err := db.DB.Model(&user).Update("disabled_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable user"})
+		return
+	}
+
+	if err := revokeUserSessions(user.ID, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "User disabled but sessions could not be revoked"})
+		return
+	}
+
+	recordActivity(c, user.ID, "account_disabled", "By an administrator")
+
+	c.JSON(http.StatusOK, gin.H{"message": "User disabled successfully"})
+}
+
+func EnableUser(c *gin.Context) {
+	targetID := c.Param("id")
+
+	result := db.DB.Model(&models.User{}).
+		Where("id = ? AND disabled_at IS NOT NULL AND anonymised_at IS NULL", targetID).
+		Update("disabled_at", nil)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable user"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found, not disabled or deleted"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User enabled successfully"})
+}
+
+// ForcePasswordReset signs the user out everywhere and refuses logins until
+// they set a new password through the emailed reset link.
+func ForcePasswordReset(c *gin.Context) {
+	targetID := c.Param("id")
+
+	var user models.User
+	if err := db.DB.Where("id = ?", targetID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if err := db.DB.Model(&user).Update("password_reset_required", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to require password reset"})
+		return
+	}
+
+	if err := revokeUserSessions(user.ID, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	recordActivity(c, user.ID, "password_reset_required", "By an administrator")
+
+	emailSent := true
+	if err := sendPasswordReset(user); err != nil {
+		utils.Logger.WithError(err).Error("Failed to send forced password reset")
+		emailSent = false
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Password reset required; the user has been signed out",
+		"emailSent": emailSent,
+	})
+}
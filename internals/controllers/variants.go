@@ -0,0 +1,468 @@
+// product variants controller
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errVariantNotFound = errors.New("Product variant not found")
+	errVariantRequired = errors.New("Product has several variants, variantId or sku is required")
+)
+
+// variantTables are the tables whose rows belong to a variant and carry its
+// product_id alongside.
+var variantTables = []string{
+	"branch_inventories",
+	"order_items",
+	"inventory_batches",
+	"restock_logs",
+	"purchase_order_lines",
+	"goods_received_lines",
+	"restock_request_lines",
+}
+
+type variantInput struct {
+	Name          string  `json:"name" binding:"required,max=100"`
+	SKU           string  `json:"sku" binding:"required,max=50"`
+	Barcode       string  `json:"barcode"`
+	Volume        string  `json:"volume" binding:"max=20"`
+	Unit          string  `json:"unit" binding:"max=20"`
+	PackSize      int     `json:"packSize" binding:"omitempty,min=1"`
+	Price         float64 `json:"price" binding:"required,min=0"`
+	OriginalPrice float64 `json:"originalPrice" binding:"min=0"`
+}
+
+// newVariant validates input and builds the variant, returning a message for
+// the client when it is not acceptable.
+func newVariant(tx *gorm.DB, productID string, input variantInput) (models.ProductVariant, string) {
+	variant := models.ProductVariant{
+		ProductID:     productID,
+		Name:          input.Name,
+		SKU:           strings.ToUpper(strings.TrimSpace(input.SKU)),
+		Volume:        "500ml",
+		Unit:          "single",
+		PackSize:      1,
+		Price:         input.Price,
+		OriginalPrice: input.OriginalPrice,
+		Status:        "active",
+	}
+
+	if input.Volume != "" {
+		variant.Volume = input.Volume
+	}
+	if input.Unit != "" {
+		variant.Unit = input.Unit
+	}
+	if input.PackSize > 0 {
+		variant.PackSize = input.PackSize
+	}
+	if variant.OriginalPrice == 0 {
+		variant.OriginalPrice = variant.Price
+	}
+
+	if msg := checkVariantCodes(tx, "", variant.SKU, input.Barcode); msg != "" {
+		return variant, msg
+	}
+	if input.Barcode != "" {
+		barcode := utils.NormalizeBarcode(input.Barcode)
+		variant.Barcode = &barcode
+	}
+
+	return variant, ""
+}
+
+// checkVariantCodes makes sure a SKU and barcode are well formed and not used
+// by any variant other than excludeID.
+func checkVariantCodes(tx *gorm.DB, excludeID, sku, barcode string) string {
+	if sku != "" {
+		var count int64
+		tx.Model(&models.ProductVariant{}).Where("sku = ? AND id::text <> ?", sku, excludeID).Count(&count)
+		if count > 0 {
+			return "Another variant already has SKU " + sku
+		}
+	}
+
+	if barcode != "" {
+		barcode = utils.NormalizeBarcode(barcode)
+		if !utils.ValidBarcode(barcode) {
+			return "Barcode must be a valid EAN-13, EAN-8 or UPC-A code"
+		}
+		var count int64
+		tx.Model(&models.ProductVariant{}).
+			Where("barcode IN ? AND id::text <> ?", utils.BarcodeForms(barcode), excludeID).
+			Count(&count)
+		if count > 0 {
+			return "Another variant already has barcode " + barcode
+		}
+	}
+
+	return ""
+}
+
+// refreshProductSummary brings a product's price (that of its cheapest active
+// variant) and the variant text used by search back in step with its variants.
+func refreshProductSummary(tx *gorm.DB, productIDs ...string) error {
+	return tx.Exec(`UPDATE products SET
+			price = coalesce((SELECT v.price FROM product_variants v
+				WHERE v.product_id = products.id AND v.status = 'active' AND v.deleted_at IS NULL
+				ORDER BY v.price LIMIT 1), price),
+			original_price = coalesce((SELECT v.original_price FROM product_variants v
+				WHERE v.product_id = products.id AND v.status = 'active' AND v.deleted_at IS NULL
+				ORDER BY v.price LIMIT 1), original_price),
+			variant_text = coalesce((SELECT string_agg(v.name || ' ' || v.volume || ' ' || v.sku || coalesce(' ' || v.barcode, ''), ' ')
+				FROM product_variants v WHERE v.product_id = products.id AND v.deleted_at IS NULL), '')
+		WHERE id IN ?`, productIDs).Error
+}
+
+// pickVariant chooses the variant a line refers to from candidates. Lines may
+// name the variant by id or SKU; clients written before variants existed send
+// only productId, which still works while the product has a single variant.
+func pickVariant(candidates []models.ProductVariant, productID, variantID, sku string) (models.ProductVariant, error) {
+	matches := []models.ProductVariant{}
+	for _, variant := range candidates {
+		switch {
+		case variantID != "":
+			if variant.ID != variantID {
+				continue
+			}
+		case sku != "":
+			if !strings.EqualFold(variant.SKU, sku) {
+				continue
+			}
+		case productID == "":
+			continue
+		}
+		if productID != "" && variant.ProductID != productID {
+			continue
+		}
+		matches = append(matches, variant)
+	}
+
+	if len(matches) == 0 {
+		return models.ProductVariant{}, errVariantNotFound
+	}
+	if len(matches) > 1 {
+		return models.ProductVariant{}, errVariantRequired
+	}
+	return matches[0], nil
+}
+
+// findVariantCandidates loads every variant any of the given ids or SKUs could
+// refer to, for resolving many lines with one query.
+func findVariantCandidates(tx *gorm.DB, productIDs, variantIDs, skus []string) ([]models.ProductVariant, error) {
+	for i := range skus {
+		skus[i] = strings.ToUpper(skus[i])
+	}
+
+	var variants []models.ProductVariant
+	err := tx.Where("id::text IN ? OR sku IN ? OR product_id::text IN ?", variantIDs, skus, productIDs).
+		Find(&variants).Error
+	return variants, err
+}
+
+// resolveVariant finds the variant for a single line (see pickVariant).
+func resolveVariant(tx *gorm.DB, productID, variantID, sku string) (models.ProductVariant, error) {
+	candidates, err := findVariantCandidates(tx, []string{productID}, []string{variantID}, []string{sku})
+	if err != nil {
+		return models.ProductVariant{}, err
+	}
+	return pickVariant(candidates, productID, variantID, sku)
+}
+
+// CreateVariant adds a size or pack to an existing product.
+func CreateVariant(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body variantInput
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var product models.Product
+	if err := db.DB.First(&product, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	variant, msg := newVariant(db.DB, product.ID, body)
+	if msg != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Create(&variant).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create variant"})
+		return
+	}
+
+	if err := recordInitialPrice(tx, variant, userID.(string)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record variant price"})
+		return
+	}
+
+	if err := refreshProductSummary(tx, product.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, variant)
+}
+
+// UpdateVariant changes a variant. Setting productId moves the variant, with
+// its stock and history, to another product; this is how products that were
+// separate per size are merged. Price changes are added to the variant's
+// price history.
+func UpdateVariant(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		ProductID     *string  `json:"productId"`
+		Name          *string  `json:"name" binding:"omitempty,min=1,max=100"`
+		SKU           *string  `json:"sku" binding:"omitempty,min=1,max=50"`
+		Barcode       *string  `json:"barcode"` // "" removes the barcode
+		Volume        *string  `json:"volume" binding:"omitempty,min=1,max=20"`
+		Unit          *string  `json:"unit" binding:"omitempty,min=1,max=20"`
+		PackSize      *int     `json:"packSize" binding:"omitempty,min=1"`
+		Price         *float64 `json:"price" binding:"omitempty,min=0"`
+		OriginalPrice *float64 `json:"originalPrice" binding:"omitempty,min=0"`
+		Status        *string  `json:"status" binding:"omitempty,oneof=active inactive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var variant models.ProductVariant
+	if err := db.DB.First(&variant, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	sku, barcode := "", ""
+
+	if body.Name != nil {
+		updates["name"] = *body.Name
+	}
+	if body.SKU != nil {
+		sku = strings.ToUpper(strings.TrimSpace(*body.SKU))
+		updates["sku"] = sku
+	}
+	if body.Barcode != nil {
+		if *body.Barcode == "" {
+			updates["barcode"] = nil
+		} else {
+			barcode = utils.NormalizeBarcode(*body.Barcode)
+			updates["barcode"] = barcode
+		}
+	}
+	if msg := checkVariantCodes(db.DB, variant.ID, sku, barcode); msg != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+		return
+	}
+	if body.Volume != nil {
+		updates["volume"] = *body.Volume
+	}
+	if body.Unit != nil {
+		updates["unit"] = *body.Unit
+	}
+	if body.PackSize != nil {
+		updates["pack_size"] = *body.PackSize
+	}
+	if body.Price != nil {
+		updates["price"] = *body.Price
+	}
+	if body.OriginalPrice != nil {
+		updates["original_price"] = *body.OriginalPrice
+	}
+	if body.Status != nil {
+		updates["status"] = *body.Status
+	}
+
+	previousProductID := variant.ProductID
+	if body.ProductID != nil && *body.ProductID != variant.ProductID {
+		var target models.Product
+		if err := db.DB.First(&target, "id = ?", *body.ProductID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Target product not found"})
+			return
+		}
+		updates["product_id"] = target.ID
+	}
+
+	if len(updates) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No changes provided"})
+		return
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&variant, "id = ?", variant.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variant"})
+		return
+	}
+
+	if body.Price != nil || body.OriginalPrice != nil {
+		price, originalPrice := variant.Price, variant.OriginalPrice
+		if body.Price != nil {
+			price = *body.Price
+		}
+		if body.OriginalPrice != nil {
+			originalPrice = *body.OriginalPrice
+		}
+		changedBy := userID.(string)
+		if err := recordPriceChange(tx, variant, price, originalPrice, "manual", &changedBy, nil); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record price change"})
+			return
+		}
+	}
+
+	if err := tx.Model(&variant).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update variant"})
+		return
+	}
+
+	productIDs := []string{previousProductID}
+	if newProductID, moved := updates["product_id"].(string); moved {
+		for _, table := range variantTables {
+			if err := tx.Table(table).Where("variant_id = ?", variant.ID).Update("product_id", newProductID).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move variant history"})
+				return
+			}
+		}
+		productIDs = append(productIDs, newProductID)
+	}
+
+	if err := refreshProductSummary(tx, productIDs...); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	tx.Commit()
+
+	db.DB.First(&variant, "id = ?", variant.ID)
+	c.JSON(http.StatusOK, variant)
+}
+
+// DeleteVariant removes a variant that was never stocked, sold or ordered.
+// Variants with history should be made inactive instead. A product keeps at
+// least one variant.
+func DeleteVariant(c *gin.Context) {
+	var variant models.ProductVariant
+	if err := db.DB.First(&variant, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Variant not found"})
+		return
+	}
+
+	var siblings int64
+	db.DB.Model(&models.ProductVariant{}).Where("product_id = ?", variant.ProductID).Count(&siblings)
+	if siblings <= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a product's only variant"})
+		return
+	}
+
+	for _, table := range variantTables {
+		var count int64
+		db.DB.Table(table).Where("variant_id = ?", variant.ID).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a variant with stock or order history, make it inactive instead"})
+			return
+		}
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Delete(&variant).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete variant"})
+		return
+	}
+
+	if err := refreshProductSummary(tx, variant.ProductID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Variant deleted successfully"})
+}
+
+// LookupBarcode finds the variant for a scanned barcode (or a SKU typed in
+// instead). With ?branchId= it also reports that branch's stock.
+func LookupBarcode(c *gin.Context) {
+	// Barcodes are compared without spaces or dashes, SKUs as typed
+	code := utils.NormalizeBarcode(c.Param("code"))
+	sku := strings.ToUpper(strings.TrimSpace(c.Param("code")))
+
+	var variant models.ProductVariant
+	if err := db.DB.
+		Preload("Product", func(tx *gorm.DB) *gorm.DB {
+			return tx.Preload("Category").Preload("Tags")
+		}).
+		Where("barcode IN ? OR sku = ?", utils.BarcodeForms(code), sku).
+		Clauses(clause.OrderBy{Expression: clause.Expr{SQL: "barcode IS NULL"}}).
+		First(&variant).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No product has this barcode"})
+		return
+	}
+
+	product := variant.Product
+	variant.Product = nil
+	if product == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No product has this barcode"})
+		return
+	}
+
+	response := gin.H{
+		"variant": variant,
+		"product": product,
+	}
+
+	if branchID := c.Query("branchId"); branchID != "" {
+		var inventory models.BranchInventory
+		quantity := 0
+		if err := db.DB.Where("branch_id = ? AND variant_id = ?", branchID, variant.ID).First(&inventory).Error; err == nil {
+			quantity = inventory.Quantity
+		}
+		response["stock"] = gin.H{
+			"branchId":  branchID,
+			"quantity":  quantity,
+			"available": quantity > 0,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
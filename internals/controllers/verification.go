@@ -0,0 +1,410 @@
+// verification and password reset controller
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/services"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	phoneVerificationTTL = 10 * time.Minute
+	passwordResetTTL     = time.Hour
+	phoneCodeLength      = 6
+	maxCodeAttempts      = 5
+)
+
+var errInvalidUserToken = errors.New("Invalid or expired token")
+
+func appURL() string {
+	if url := os.Getenv("APP_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// issueUserToken creates a new single-use token for userID, replacing any
+// unused token issued earlier for the same purpose. Short numeric codes are
+// used where the user has to type the token in.
+func issueUserToken(userID, purpose, target string, ttl time.Duration, numeric bool) (string, error) {
+	var raw string
+	var err error
+	if numeric {
+		raw, err = utils.GenerateNumericCode(phoneCodeLength)
+	} else {
+		raw, err = utils.GenerateRefreshToken()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	tx := db.DB.Begin()
+
+	if err := tx.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	token := models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(raw),
+		Target:    target,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tx.Create(&token).Error; err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	tx.Commit()
+	return raw, nil
+}
+
+// redeemUserToken marks a matching, unexpired token as used and returns it.
+// Link tokens are looked up by hash alone; numeric codes are only checked
+// against the latest code issued to userID, and each wrong guess counts
+// towards maxCodeAttempts.
+func redeemUserToken(purpose, raw, userID string) (*models.UserToken, error) {
+	tx := db.DB.Begin()
+
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("purpose = ? AND used_at IS NULL AND expires_at > ?", purpose, time.Now())
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	} else {
+		query = query.Where("token_hash = ?", utils.HashToken(raw))
+	}
+
+	var token models.UserToken
+	if err := query.Order("created_at DESC").First(&token).Error; err != nil {
+		tx.Rollback()
+		return nil, errInvalidUserToken
+	}
+
+	if token.TokenHash != utils.HashToken(raw) {
+		updates := map[string]interface{}{"attempts": token.Attempts + 1}
+		if token.Attempts+1 >= maxCodeAttempts {
+			updates["used_at"] = time.Now()
+		}
+		tx.Model(&token).Updates(updates)
+		tx.Commit()
+		return nil, errInvalidUserToken
+	}
+
+	if err := tx.Model(&token).Update("used_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	tx.Commit()
+	return &token, nil
+}
+
+// sendEmailVerification issues a verification link for the user's current
+// email address.
+func sendEmailVerification(user models.User) error {
+	raw, err := issueUserToken(user.ID, "email_verification", user.Email, emailVerificationTTL, false)
+	if err != nil {
+		return err
+	}
+
+	return services.NewNotifier().Send(services.Notification{
+		Channel: "email",
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening this link within 24 hours:\n%s/verify-email?token=%s",
+			user.Name, appURL(), raw),
+	})
+}
+
+func RequestEmailVerification(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified"})
+		return
+	}
+
+	if err := sendEmailVerification(user); err != nil {
+		utils.Logger.WithError(err).Error("Failed to send email verification")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+func ConfirmEmailVerification(c *gin.Context) {
+	var body struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	token, err := redeemUserToken("email_verification", body.Token, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only verify the address the link was sent to, in case it has changed since
+	result := db.DB.Model(&models.User{}).
+		Where("id = ? AND email = ?", token.UserID, token.Target).
+		Update("email_verified_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidUserToken.Error()})
+		return
+	}
+
+	recordActivity(c, token.UserID, "email_verified", token.Target)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+func RequestPhoneVerification(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if user.PhoneVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Phone number is already verified"})
+		return
+	}
+
+	code, err := issueUserToken(user.ID, "phone_verification", user.Phone, phoneVerificationTTL, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create verification code"})
+		return
+	}
+
+	message := fmt.Sprintf("Your verification code is %s. It expires in 10 minutes.", code)
+	if err := services.NewSMSProvider().SendSMS(user.Phone, message); err != nil {
+		utils.Logger.WithError(err).Error("Failed to send phone verification")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent"})
+}
+
+func ConfirmPhoneVerification(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	token, err := redeemUserToken("phone_verification", body.Code, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+
+	result := db.DB.Model(&models.User{}).
+		Where("id = ? AND phone = ?", token.UserID, token.Target).
+		Update("phone_verified_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify phone number"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
+		return
+	}
+
+	recordActivity(c, token.UserID, "phone_verified", token.Target)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Phone number verified successfully"})
+}
+
+// sendPasswordReset emails the user a link to set a new password.
+func sendPasswordReset(user models.User) error {
+	raw, err := issueUserToken(user.ID, "password_reset", user.Email, passwordResetTTL, false)
+	if err != nil {
+		return err
+	}
+
+	return services.NewNotifier().Send(services.Notification{
+		Channel: "email",
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nReset your password by opening this link within one hour:\n%s/reset-password?token=%s\n\nIf you did not ask for this, you can ignore this email.",
+			user.Name, appURL(), raw),
+	})
+}
+
+// RequestPasswordReset emails a reset link. It responds the same way whether
+// or not the email belongs to an account so it cannot be used to find users.
+func RequestPasswordReset(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	response := gin.H{"message": "If an account exists for this email, a password reset link has been sent"}
+
+	var user models.User
+	if err := db.DB.Where("email = ?", body.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if err := sendPasswordReset(user); err != nil {
+		utils.Logger.WithError(err).Error("Failed to send password reset")
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ConfirmPasswordReset sets a new password and signs the user out everywhere.
+func ConfirmPasswordReset(c *gin.Context) {
+	var body struct {
+		Token           string `json:"token" binding:"required"`
+		Password        string `json:"password" binding:"required,min=8"`
+		ConfirmPassword string `json:"confirmPassword" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format; passwords must be at least 8 characters"})
+		return
+	}
+
+	if body.Password != body.ConfirmPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passwords do not match"})
+		return
+	}
+
+	token, err := redeemUserToken("password_reset", body.Token, "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.DB.Model(&models.User{}).
+		Where("id = ?", token.UserID).
+		Updates(map[string]interface{}{
+			"password":                utils.HashPassword(body.Password),
+			"password_reset_required": false,
+		}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := revokeUserSessions(token.UserID, ""); err != nil {
+		utils.Logger.WithError(err).Error("Failed to revoke sessions after password reset")
+	}
+
+	// Whoever could read the reset email owns the account, so lift any lockout
+	// and treat the address as verified
+	recordLoginSuccess(token.Target)
+	db.DB.Model(&models.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", token.UserID, token.Target).
+		Update("email_verified_at", time.Now())
+
+	recordActivity(c, token.UserID, "password_reset", "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully; please log in again"})
+}
+
+// ChangePassword updates the caller's password and signs out their other
+// sessions, keeping the current one.
+func ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var body struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required,min=8"`
+		ConfirmPassword string `json:"confirmPassword" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format; passwords must be at least 8 characters"})
+		return
+	}
+
+	if body.NewPassword != body.ConfirmPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passwords do not match"})
+		return
+	}
+
+	var user models.User
+	if err := db.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if !utils.CheckPassword(body.CurrentPassword, user.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	if err := db.DB.Model(&user).Update("password", utils.HashPassword(body.NewPassword)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	if err := revokeUserSessions(user.ID, c.GetString("sessionID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed but other sessions could not be signed out"})
+		return
+	}
+
+	recordActivity(c, user.ID, "password_changed", "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
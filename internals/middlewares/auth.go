@@ -2,21 +2,37 @@
 package middlewares
 
 import (
+	"encoding/json"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/permissions"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 const defaultAuthCookieName = "auth_token"
 
+// JWTAuthMiddleware authenticates the caller from a JWT in the Authorization
+// header or auth cookie, or from an API key (see authenticateAPIKey).
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		tokenString := ""
 
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+		if strings.HasPrefix(authHeader, "Bearer "+utils.APIKeyPrefix) {
+			authenticateAPIKey(c, strings.TrimPrefix(authHeader, "Bearer "))
+			return
+		}
+
 		if authHeader != "" {
 			tokenString = strings.Replace(authHeader, "Bearer ", "", 1)
 			if tokenString == authHeader {
@@ -42,36 +58,152 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := utils.ValidateJWT(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		userID, _ := claims["user_id"].(string)
+		role, _ := claims["role"].(string)
+		sessionID, _ := claims["sid"].(string)
+
+		// Tokens are only honoured while their session is live, so logout and
+		// revocation take effect before the token expires
+		if sessionID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please log in again"})
 			c.Abort()
 			return
 		}
 
-		userID, _ := claims["user_id"].(string)
-		role, _ := claims["role"].(string)
+		var session models.Session
+		if err := db.DB.Select("id", "revoked_at").
+			Where("id = ? AND user_id = ?", sessionID, userID).
+			First(&session).Error; err != nil || session.RevokedAt != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
 
 		c.Set("userID", userID)
 		c.Set("userRole", role)
+		c.Set("sessionID", sessionID)
 
 		c.Next()
 	}
 }
 
+// authenticateAPIKey lets a machine client in with an API key. Keys only work
+// on admin routes, where RequirePermission checks the key's scopes instead of
+// a role. Actions are attributed to the admin who created the key, and the key
+// can do no more than its creator's current role allows: scopes the role no
+// longer grants are ignored, and a creator moved to a branch role limits the
+// key to their branches.
+func authenticateAPIKey(c *gin.Context, rawKey string) {
+	var key models.APIKey
+	if err := db.DB.Where("key_hash = ?", utils.HashToken(rawKey)).First(&key).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		c.Abort()
+		return
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key has been revoked"})
+		c.Abort()
+		return
+	}
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key has expired"})
+		c.Abort()
+		return
+	}
+
+	// A key stops working when the admin who created it is disabled
+	var creator models.User
+	if err := db.DB.Select("id", "role").
+		Where("id = ? AND disabled_at IS NULL", key.CreatedBy).
+		First(&creator).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API key owner has been disabled"})
+		c.Abort()
+		return
+	}
+
+	if !strings.HasPrefix(c.FullPath(), "/api/v1/admin/") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys can only be used on admin routes"})
+		c.Abort()
+		return
+	}
+
+	keyScopes := []string{}
+	if err := json.Unmarshal([]byte(key.Scopes), &keyScopes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read API key scopes"})
+		c.Abort()
+		return
+	}
+	scopes := []string{}
+	for _, scope := range keyScopes {
+		if permissions.Has(creator.Role, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	branchIDs := []string{}
+	if err := db.DB.Table("api_key_branches").
+		Where("api_key_id = ?", key.ID).
+		Pluck("branch_id", &branchIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API key branches"})
+		c.Abort()
+		return
+	}
+
+	branchScoped := permissions.IsBranchScoped(creator.Role)
+	if branchScoped {
+		creatorBranchIDs := []string{}
+		if err := db.DB.Table("user_branches").
+			Where("user_id = ?", creator.ID).
+			Pluck("branch_id", &creatorBranchIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load branch assignments"})
+			c.Abort()
+			return
+		}
+		if len(branchIDs) == 0 {
+			branchIDs = creatorBranchIDs
+		} else {
+			assigned := map[string]bool{}
+			for _, id := range creatorBranchIDs {
+				assigned[id] = true
+			}
+			kept := []string{}
+			for _, id := range branchIDs {
+				if assigned[id] {
+					kept = append(kept, id)
+				}
+			}
+			branchIDs = kept
+		}
+	}
+
+	// Recording every call would mean a write per request; a minute is precise enough
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute {
+		db.DB.Model(&key).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.ClientIP(),
+		})
+	}
+
+	c.Set("userID", key.CreatedBy)
+	c.Set("userRole", "api_key")
+	c.Set("apiKeyID", key.ID)
+	c.Set("apiKeyScopes", scopes)
+	if len(branchIDs) > 0 || branchScoped {
+		c.Set("apiKeyBranches", branchIDs)
+	}
+
+	c.Next()
+}
+
 func AdminAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("userRole")
@@ -91,6 +223,77 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// RequirePermission allows the request through only if the caller's role
+// grants permission. For branch-scoped roles it also loads the branches the
+// user is assigned to and stores them as "branchScope" for controllers to
+// filter on; HQ admins get no scope and see every branch.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, exists := c.Get("userRole")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
+			c.Abort()
+			return
+		}
+
+		// API keys carry their own scopes and optional branch restriction
+		if scopes, isAPIKey := c.Get("apiKeyScopes"); isAPIKey {
+			allowed := false
+			for _, scope := range scopes.([]string) {
+				if scope == permission {
+					allowed = true
+				}
+			}
+			if !allowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key does not have this scope", "permission": permission})
+				c.Abort()
+				return
+			}
+			if branchIDs, restricted := c.Get("apiKeyBranches"); restricted {
+				c.Set("branchScope", branchIDs)
+			}
+			c.Next()
+			return
+		}
+
+		role, _ := userRole.(string)
+		if !permissions.Has(role, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied", "permission": permission})
+			c.Abort()
+			return
+		}
+
+		// Admins who have not enrolled in 2FA can still reach /auth/2fa to do so
+		if permissions.RequiresTwoFactor(role) {
+			var user models.User
+			if err := db.DB.Select("id", "two_factor_enabled_at").
+				Where("id = ?", c.GetString("userID")).
+				First(&user).Error; err != nil || user.TwoFactorEnabledAt == nil {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":             "Two-factor authentication must be enabled to use this route",
+					"twoFactorRequired": true,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		if permissions.IsBranchScoped(role) {
+			branchIDs := []string{}
+			if err := db.DB.Table("user_branches").
+				Where("user_id = ?", c.GetString("userID")).
+				Pluck("branch_id", &branchIDs).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load branch assignments"})
+				c.Abort()
+				return
+			}
+			c.Set("branchScope", branchIDs)
+		}
+
+		c.Next()
+	}
+}
+
 func CustomerAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("userRole")
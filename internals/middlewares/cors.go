@@ -30,6 +30,12 @@ func CORSMiddleware() gin.HandlerFunc {
 			"Content-Type",
 			"Authorization",
 			"Accept",
+			"If-Match",
+		},
+
+		// Needed by browser clients for optimistic concurrency on updates
+		ExposeHeaders: []string{
+			"ETag",
 		},
 
 		AllowCredentials: true,
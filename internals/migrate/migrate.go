@@ -2,11 +2,17 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/initialisers"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"gorm.io/gorm"
 )
 
 func init() {
@@ -15,6 +21,8 @@ func init() {
 }
 
 func main() {
+	flag.Parse()
+
 	// Enable UUID extension for PostgreSQL
 	if err := db.DB.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\"").Error; err != nil {
 		fmt.Println("Warning: Could not create uuid-ossp extension:", err)
@@ -25,19 +33,348 @@ func main() {
 		}
 	}
 
+	// Trigram similarity for typo-tolerant product search
+	if err := db.DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		fmt.Println("Warning: Could not create pg_trgm extension, product search will fail:", err)
+	}
+
+	prepareSchemaChanges()
+
 	db.DB.AutoMigrate(
 		&models.User{},
+		&models.Session{},
+		&models.UserToken{},
+		&models.TwoFactorRecoveryCode{},
+		&models.LoginThrottle{},
+		&models.APIKey{},
+		&models.AccountActivity{},
+		&models.AuditLog{},
+		&models.Brand{},
+		&models.BranchLocation{},
 		&models.Branch{},
+		&models.Address{},
+		&models.Category{},
+		&models.Tag{},
 		&models.Product{},
+		&models.ProductVariant{},
+		&models.ProductImage{},
+		&models.PriceChange{},
+		&models.ScheduledPriceChange{},
+		&models.Review{},
 		&models.BranchInventory{},
 		&models.Order{},
 		&models.OrderItem{},
 		&models.Payment{},
+		&models.Supplier{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderLine{},
+		&models.GoodsReceivedNote{},
+		&models.GoodsReceivedLine{},
 		&models.RestockLog{},
+		&models.RestockRequest{},
+		&models.RestockRequestLine{},
+		&models.InventoryBatch{},
+		&models.OrderItemBatch{},
 	)
 
+	addBranchLocationKey()
+	migrateLegacyData()
+	createSearchIndexes()
+
 	fmt.Println("Database migration completed")
 	
 	// Seed initial data
 	seedData()
 }
+
+// prepareSchemaChanges runs before AutoMigrate. AutoMigrate only creates check
+// constraints that are missing, so the ones whose allowed values have changed
+// are dropped here (and existing rows brought in line) to be recreated.
+func prepareSchemaChanges() {
+	if db.DB.Migrator().HasTable(&models.User{}) {
+		db.DB.Migrator().DropConstraint(&models.User{}, "chk_users_role")
+		// 'staff' was the single branch role before cashiers and branch managers
+		db.DB.Exec("UPDATE users SET role = 'branch_manager' WHERE role = 'staff'")
+	}
+	if db.DB.Migrator().HasTable(&models.UserToken{}) {
+		db.DB.Migrator().DropConstraint(&models.UserToken{}, "chk_user_tokens_purpose")
+	}
+
+	// Supplier names used to be unique among deleted suppliers too. AutoMigrate
+	// skips an index whose name exists, so the old one is dropped to be
+	// recreated for live suppliers only.
+	var fullSupplierIndexes int64
+	db.DB.Raw(`SELECT count(*) FROM pg_indexes
+		WHERE indexname = 'idx_suppliers_name' AND indexdef NOT LIKE '%WHERE%'`).Scan(&fullSupplierIndexes)
+	if fullSupplierIndexes > 0 {
+		db.DB.Exec("DROP INDEX idx_suppliers_name")
+	}
+
+	// Brands and branch locations used to be fixed lists in check constraints.
+	// They are now tables referenced by foreign key, so every value already in
+	// use has to exist before AutoMigrate adds the keys.
+	if err := db.DB.AutoMigrate(&models.Brand{}, &models.BranchLocation{}); err != nil {
+		fmt.Println("Warning: Could not create brands and branch locations:", err)
+		return
+	}
+	if db.DB.Migrator().HasTable(&models.Product{}) {
+		db.DB.Migrator().DropConstraint(&models.Product{}, "chk_products_brand")
+		db.DB.Exec(`INSERT INTO brands (name, status, created_at, updated_at)
+			SELECT DISTINCT brand, 'active', NOW(), NOW() FROM products
+			ON CONFLICT (name) DO NOTHING`)
+	}
+	if db.DB.Migrator().HasTable(&models.OrderItem{}) {
+		db.DB.Migrator().DropConstraint(&models.OrderItem{}, "chk_order_items_product_brand")
+		db.DB.Exec(`INSERT INTO brands (name, status, created_at, updated_at)
+			SELECT DISTINCT product_brand, 'active', NOW(), NOW() FROM order_items
+			ON CONFLICT (name) DO NOTHING`)
+	}
+	if db.DB.Migrator().HasTable(&models.Branch{}) {
+		db.DB.Migrator().DropConstraint(&models.Branch{}, "chk_branches_name")
+		db.DB.Exec(`INSERT INTO branch_locations (name, status, created_at, updated_at)
+			SELECT DISTINCT name, 'active', NOW(), NOW() FROM branches
+			ON CONFLICT (name) DO NOTHING`)
+	}
+
+	// Products keep their volume and unit columns until their variants exist
+	if db.DB.Migrator().HasColumn(&models.Product{}, "volume") {
+		if err := migrateProductVariants(); err != nil {
+			fmt.Println("Warning: Could not create product variants:", err)
+		}
+	}
+}
+
+// variantTables are the tables that gained a variant_id alongside product_id.
+var variantTables = []string{
+	"branch_inventories",
+	"order_items",
+	"inventory_batches",
+	"restock_logs",
+	"purchase_order_lines",
+	"goods_received_lines",
+	"restock_request_lines",
+}
+
+// migrateProductVariants gives every existing product a single variant
+// carrying its old volume, unit and price, and points stock, orders and
+// purchasing at it so AutoMigrate can make variant_id required. A volume such
+// as "500ml x 24" becomes a 500ml variant with a pack size of 24. The table is
+// created, filled and the old columns dropped in one transaction, so a failed
+// run leaves products as they were and is retried on the next migration.
+func migrateProductVariants() error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&models.ProductVariant{}); err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`INSERT INTO product_variants
+				(product_id, name, sku, volume, unit, pack_size, price, original_price, status, created_at, updated_at, deleted_at)
+			SELECT p.id,
+				coalesce(nullif(p.volume, ''), '500ml'),
+				upper(left(trim(both '-' from regexp_replace(p.name, '[^A-Za-z0-9]+', '-', 'g')), 40)) || '-' || upper(left(p.id::text, 4)),
+				coalesce(nullif(trim(split_part(p.volume, ' x ', 1)), ''), '500ml'),
+				coalesce(nullif(p.unit, ''), 'single'),
+				CASE WHEN p.volume ~ ' x [0-9]+$' THEN substring(p.volume from ' x ([0-9]+)$')::int ELSE 1 END,
+				p.price, p.original_price, 'active', p.created_at, NOW(), p.deleted_at
+			FROM products p
+			WHERE NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = p.id)`).Error; err != nil {
+			return err
+		}
+
+		for _, table := range variantTables {
+			if !tx.Migrator().HasTable(table) {
+				continue
+			}
+			if err := tx.Exec("ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS variant_id uuid").Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE " + table + " t SET variant_id = pv.id FROM product_variants pv " +
+				"WHERE pv.product_id = t.product_id AND t.variant_id IS NULL").Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("ALTER TABLE products ADD COLUMN IF NOT EXISTS variant_text text NOT NULL DEFAULT ''").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`UPDATE products SET variant_text = coalesce((SELECT string_agg(v.name || ' ' || v.volume || ' ' || v.sku, ' ')
+			FROM product_variants v WHERE v.product_id = products.id), '')`).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Migrator().DropColumn(&models.Product{}, "volume"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&models.Product{}, "unit")
+	})
+}
+
+// addBranchLocationKey makes branches.name reference branch_locations.name.
+// GORM cannot declare this key because both tables have a name column, so it
+// would read the relation as the location belonging to the branch. Renaming a
+// location renames its branches through ON UPDATE CASCADE.
+func addBranchLocationKey() {
+	if db.DB.Migrator().HasConstraint(&models.Branch{}, "fk_branches_location") {
+		return
+	}
+	if err := db.DB.Exec(`ALTER TABLE branches ADD CONSTRAINT fk_branches_location
+		FOREIGN KEY (name) REFERENCES branch_locations (name) ON UPDATE CASCADE ON DELETE RESTRICT`).Error; err != nil {
+		fmt.Println("Warning: Could not link branches to branch locations:", err)
+	}
+}
+
+// migrateLegacyData runs after AutoMigrate to move data out of columns that
+// the models no longer have.
+func migrateLegacyData() {
+	if db.DB.Migrator().HasColumn(&models.User{}, "branch_id") {
+		if err := db.DB.Exec(`INSERT INTO user_branches (user_id, branch_id)
+			SELECT id, branch_id FROM users WHERE branch_id IS NOT NULL
+			ON CONFLICT DO NOTHING`).Error; err != nil {
+			fmt.Println("Warning: Could not copy staff branch assignments:", err)
+		} else {
+			db.DB.Migrator().DropColumn(&models.User{}, "branch_id")
+		}
+	}
+
+	// Earlier versions seeded admin@drinx.com with the password "password".
+	// The account is signed out and must reset its password before it can
+	// log in again, so the published credential stops working.
+	var seededAdmin models.User
+	if err := db.DB.Where("email = ?", "admin@drinx.com").First(&seededAdmin).Error; err == nil &&
+		utils.CheckPassword("password", seededAdmin.Password) {
+		if err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&seededAdmin).Update("password_reset_required", true).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Session{}).
+				Where("user_id = ? AND revoked_at IS NULL", seededAdmin.ID).
+				Update("revoked_at", time.Now()).Error
+		}); err != nil {
+			fmt.Println("WARNING: Could not lock admin@drinx.com, which still has the old default password:", err)
+		} else {
+			fmt.Println("admin@drinx.com still had the old default password; it has been signed out and must",
+				"reset its password through the password reset email before logging in.")
+		}
+	}
+
+	// Product categories and tags used to be a free-text column and a JSON array
+	if db.DB.Migrator().HasColumn(&models.Product{}, "category") {
+		if err := migrateProductTaxonomy(); err != nil {
+			fmt.Println("Warning: Could not convert product categories and tags:", err)
+		} else {
+			db.DB.Migrator().DropColumn(&models.Product{}, "category")
+			db.DB.Migrator().DropColumn(&models.Product{}, "tags")
+		}
+	}
+
+	// Batches are indexed by variant
+	db.DB.Exec("DROP INDEX IF EXISTS idx_batch_branch_product")
+
+	// Price history starts from each variant's price when history was added.
+	// Earlier prices were never recorded, so the price is only known to hold
+	// from now on.
+	if err := db.DB.Exec(`INSERT INTO price_changes (variant_id, price, original_price, source, effective_at, created_at, updated_at)
+		SELECT v.id, v.price, v.original_price, 'migrated', now(), now(), now() FROM product_variants v
+		WHERE NOT EXISTS (SELECT 1 FROM price_changes pc WHERE pc.variant_id = v.id)`).Error; err != nil {
+		fmt.Println("Warning: Could not start price history:", err)
+	}
+
+	// Ratings used to be typed in by admins; they now come from approved
+	// reviews, so products without any are reset to no rating
+	if err := db.DB.Exec(`UPDATE products SET
+			rating = coalesce((SELECT round(avg(r.rating)::numeric, 1) FROM reviews r
+				WHERE r.product_id = products.id AND r.status = 'approved' AND r.deleted_at IS NULL), 0),
+			reviews = (SELECT count(*) FROM reviews r
+				WHERE r.product_id = products.id AND r.status = 'approved' AND r.deleted_at IS NULL)`).Error; err != nil {
+		fmt.Println("Warning: Could not recompute product ratings:", err)
+	}
+
+	// Phone OTP login looks users up by normalised phone number
+	var users []models.User
+	db.DB.Select("id", "phone").Find(&users)
+	for _, user := range users {
+		phone, err := utils.NormalizePhone(user.Phone)
+		if err != nil {
+			fmt.Printf("Warning: User %s has a phone number that cannot be normalised: %q\n", user.ID, user.Phone)
+			continue
+		}
+		if phone != user.Phone {
+			db.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("phone", phone)
+		}
+	}
+}
+
+// migrateProductTaxonomy turns the old products.category text and
+// products.tags JSON into categories, tags and product_tags rows.
+func migrateProductTaxonomy() error {
+	var legacy []struct {
+		ID       string
+		Category string
+		Tags     string
+	}
+	if err := db.DB.Raw("SELECT id, category, coalesce(tags::text, '[]') AS tags FROM products").Scan(&legacy).Error; err != nil {
+		return err
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, product := range legacy {
+			if product.Category != "" {
+				var category models.Category
+				if err := tx.Where(models.Category{Slug: utils.Slugify(product.Category)}).
+					Attrs(models.Category{Name: product.Category}).
+					FirstOrCreate(&category).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).
+					Update("category_id", category.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			var names []string
+			if err := json.Unmarshal([]byte(product.Tags), &names); err != nil {
+				fmt.Printf("Warning: Product %s has unreadable tags %q, dropping them\n", product.ID, product.Tags)
+				continue
+			}
+			kept := []string{}
+			for _, name := range names {
+				slug := utils.Slugify(name)
+				if slug == "" {
+					continue
+				}
+				var tag models.Tag
+				if err := tx.Where(models.Tag{Slug: slug}).Attrs(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("INSERT INTO product_tags (product_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING",
+					product.ID, tag.ID).Error; err != nil {
+					return err
+				}
+				kept = append(kept, name)
+			}
+			if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).
+				Update("tag_names", strings.Join(kept, " ")).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// createSearchIndexes adds the indexes AutoMigrate cannot express: product
+// search filters on expressions rather than columns. Building them locks
+// products against writes, so they are only created once; when an expression
+// changes, its index must be given a new name and the old one dropped.
+func createSearchIndexes() {
+	// Replaced when descriptions moved from the 'english' to the 'simple' configuration
+	db.DB.Exec("DROP INDEX IF EXISTS idx_products_search")
+	if err := db.DB.Exec("CREATE INDEX IF NOT EXISTS idx_products_search_simple ON products USING GIN (" +
+		models.ProductSearchVector + ")").Error; err != nil {
+		fmt.Println("Warning: Could not create product search index:", err)
+	}
+
+	if err := db.DB.Exec("CREATE INDEX IF NOT EXISTS idx_products_search_text ON products USING GIN (" +
+		models.ProductSearchText + " gin_trgm_ops)").Error; err != nil {
+		fmt.Println("Warning: Could not create product search trigram index:", err)
+	}
+}
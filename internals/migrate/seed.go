@@ -2,24 +2,59 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/db"
 	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/models"
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
 )
 
 func seedData() {
 	fmt.Println("Seeding initial data...")
 
+	seedBrands()
+	seedBranchLocations()
 	seedBranches()
 	seedProducts()
 	seedBranchInventory()
-	seedAdminUser()
+	bootstrapAdminUser()
 
 	fmt.Println("Seeding completed!")
 }
 
+// seedBrands adds the brands the store launched with. Further brands are
+// managed through the API.
+func seedBrands() {
+	for _, name := range []string{"Coke", "Fanta", "Sprite"} {
+		brand := models.Brand{Name: name, Status: "active"}
+		if err := db.DB.Where(models.Brand{Name: name}).FirstOrCreate(&brand).Error; err != nil {
+			log.Printf("Failed to create brand %s: %v", name, err)
+		}
+	}
+}
+
+// seedBranchLocations adds the towns the first branches opened in.
+func seedBranchLocations() {
+	locations := map[string]string{
+		"Nairobi": "Nairobi",
+		"Kisumu":  "Western",
+		"Mombasa": "Coast",
+		"Nakuru":  "Rift Valley",
+		"Eldoret": "North Rift",
+	}
+	for name, region := range locations {
+		location := models.BranchLocation{Name: name, Region: region, Status: "active"}
+		if err := db.DB.Where(models.BranchLocation{Name: name}).FirstOrCreate(&location).Error; err != nil {
+			log.Printf("Failed to create branch location %s: %v", name, err)
+		}
+	}
+}
+
 func seedBranches() {
 	branches := []models.Branch{
 		{
@@ -78,188 +113,166 @@ func seedBranches() {
 	}
 }
 
+// seedProduct is a catalogue entry whose category and tags are given by name.
+type seedProduct struct {
+	Name        string
+	Brand       string
+	Description string
+	Image       string
+	Category    string
+	Tags        []string
+	Variants    []models.ProductVariant
+}
+
 func seedProducts() {
-	products := []models.Product{
-		{
-			Name:          "Coca-Cola Original 500ml",
-			Brand:         "Coke",
-			Price:         60.00,
-			OriginalPrice: 65.00,
-			Description:   "Classic Coca-Cola taste. Available in single bottles or crates.",
-			Image:         "https://i.postimg.cc/y6SN9pt5/coke.png",
-			Rating:        4.8,
-			Reviews:       1250,
-			Category:      "Soft Drinks",
-			Volume:        "500ml",
-			Unit:          "single",
-			Tags:          `["popular", "classic", "carbonated", "original"]`,
-		},
-		{
-			Name:          "Coca-Cola Original 500ml Crate",
-			Brand:         "Coke",
-			Price:         1400.00,
-			OriginalPrice: 1560.00,
-			Description:   "Coca-Cola 500ml crate of 24 bottles. Perfect for parties and events.",
-			Image:         "https://i.postimg.cc/VLQkFBcd/cokes.png",
-			Rating:        4.8,
-			Reviews:       850,
-			Category:      "Soft Drinks",
-			Volume:        "500ml x 24",
-			Unit:          "crate",
-			Tags:          `["popular", "classic", "carbonated", "bulk", "crate"]`,
-		},
-		{
-			Name:          "Coca-Cola Original 1 Litre",
-			Brand:         "Coke",
-			Price:         110.00,
-			OriginalPrice: 120.00,
-			Description:   "Coca-Cola in a larger 1 litre bottle. Great for sharing.",
-			Image:         "https://i.postimg.cc/J4VzQcWQ/litre.webp",
-			Rating:        4.7,
-			Reviews:       680,
-			Category:      "Soft Drinks",
-			Volume:        "1L",
-			Unit:          "single",
-			Tags:          `["popular", "classic", "carbonated", "large", "sharing"]`,
-		},
-		{
-			Name:          "Fanta Orange 500ml",
-			Brand:         "Fanta",
-			Price:         60.00,
-			OriginalPrice: 65.00,
-			Description:   "Bursting with orange flavor. Refreshing anytime.",
-			Image:         "https://i.postimg.cc/fRMWGzyz/orangee.png",
-			Rating:        4.6,
-			Reviews:       980,
-			Category:      "Soft Drinks",
-			Volume:        "500ml",
-			Unit:          "single",
-			Tags:          `["citrus", "fruity", "orange", "refreshing"]`,
-		},
-		{
-			Name:          "Fanta Orange 500ml Crate",
-			Brand:         "Fanta",
-			Price:         1400.00,
-			OriginalPrice: 1560.00,
-			Description:   "Fanta Orange 500ml crate of 24 bottles. Bulk savings!",
-			Image:         "https://i.postimg.cc/bNcwRHjG/fantas.png",
-			Rating:        4.6,
-			Reviews:       520,
-			Category:      "Soft Drinks",
-			Volume:        "500ml x 24",
-			Unit:          "crate",
-			Tags:          `["citrus", "fruity", "orange", "bulk", "crate"]`,
-		},
+	// The catalogue is only seeded on a fresh install. Products migrated from
+	// before variants existed are one product per size and are not matched by
+	// the grouped names below.
+	var existing int64
+	db.DB.Model(&models.Product{}).Count(&existing)
+	if existing > 0 {
+		fmt.Println("Products already exist, skipping product seeding")
+		return
+	}
+
+	products := []seedProduct{
 		{
-			Name:          "Fanta Orange 2 Litre",
-			Brand:         "Fanta",
-			Price:         180.00,
-			OriginalPrice: 195.00,
-			Description:   "Fanta Orange in 2 litre bottle. Maximum refreshment.",
-			Image:         "https://i.postimg.cc/CxwM3h19/fant.png",
-			Rating:        4.5,
-			Reviews:       420,
-			Category:      "Soft Drinks",
-			Volume:        "2L",
-			Unit:          "single",
-			Tags:          `["citrus", "fruity", "orange", "large", "sharing"]`,
+			Name:        "Coca-Cola Original",
+			Brand:       "Coke",
+			Description: "Classic Coca-Cola taste. Available in single bottles, crates and litre bottles for sharing.",
+			Image:       "https://i.postimg.cc/y6SN9pt5/coke.png",
+			Category:    "Soft Drinks",
+			Tags:        []string{"popular", "classic", "carbonated", "original"},
+			Variants: []models.ProductVariant{
+				{Name: "500ml", SKU: "COKE-ORIG-500", Volume: "500ml", Unit: "single", PackSize: 1, Price: 60.00, OriginalPrice: 65.00},
+				{Name: "Crate of 24", SKU: "COKE-ORIG-500X24", Volume: "500ml", Unit: "crate", PackSize: 24, Price: 1400.00, OriginalPrice: 1560.00},
+				{Name: "1 Litre", SKU: "COKE-ORIG-1L", Volume: "1L", Unit: "single", PackSize: 1, Price: 110.00, OriginalPrice: 120.00},
+			},
 		},
 		{
-			Name:          "Sprite Lemon-Lime 500ml",
-			Brand:         "Sprite",
-			Price:         60.00,
-			OriginalPrice: 65.00,
-			Description:   "Crisp, clean lemon-lime flavor. Caffeine-free.",
-			Image:         "https://i.postimg.cc/wj9xCqvr/sp.png",
-			Rating:        4.7,
-			Reviews:       1120,
-			Category:      "Soft Drinks",
-			Volume:        "500ml",
-			Unit:          "single",
-			Tags:          `["lemon", "lime", "crisp", "caffeine-free"]`,
+			Name:        "Fanta Orange",
+			Brand:       "Fanta",
+			Description: "Bursting with orange flavor. Refreshing anytime.",
+			Image:       "https://i.postimg.cc/fRMWGzyz/orangee.png",
+			Category:    "Soft Drinks",
+			Tags:        []string{"citrus", "fruity", "orange", "refreshing"},
+			Variants: []models.ProductVariant{
+				{Name: "500ml", SKU: "FANTA-ORNG-500", Volume: "500ml", Unit: "single", PackSize: 1, Price: 60.00, OriginalPrice: 65.00},
+				{Name: "Crate of 24", SKU: "FANTA-ORNG-500X24", Volume: "500ml", Unit: "crate", PackSize: 24, Price: 1400.00, OriginalPrice: 1560.00},
+				{Name: "2 Litre", SKU: "FANTA-ORNG-2L", Volume: "2L", Unit: "single", PackSize: 1, Price: 180.00, OriginalPrice: 195.00},
+			},
 		},
 		{
-			Name:          "Sprite Lemon-Lime 500ml Crate",
-			Brand:         "Sprite",
-			Price:         1400.00,
-			OriginalPrice: 1560.00,
-			Description:   "Sprite 500ml crate of 24 bottles. Stock up and save.",
-			Image:         "https://i.postimg.cc/N0Ms0dR7/spritecrate.png",
-			Rating:        4.7,
-			Reviews:       640,
-			Category:      "Soft Drinks",
-			Volume:        "500ml x 24",
-			Unit:          "crate",
-			Tags:          `["lemon", "lime", "crisp", "caffeine-free", "bulk", "crate"]`,
+			Name:        "Sprite Lemon-Lime",
+			Brand:       "Sprite",
+			Description: "Crisp, clean lemon-lime flavor. Caffeine-free.",
+			Image:       "https://i.postimg.cc/wj9xCqvr/sp.png",
+			Category:    "Soft Drinks",
+			Tags:        []string{"lemon", "lime", "crisp", "caffeine-free"},
+			Variants: []models.ProductVariant{
+				{Name: "500ml", SKU: "SPRITE-LL-500", Volume: "500ml", Unit: "single", PackSize: 1, Price: 60.00, OriginalPrice: 65.00},
+				{Name: "Crate of 24", SKU: "SPRITE-LL-500X24", Volume: "500ml", Unit: "crate", PackSize: 24, Price: 1400.00, OriginalPrice: 1560.00},
+			},
 		},
 		{
-			Name:          "Coca-Cola Zero Sugar 500ml",
-			Brand:         "Coke",
-			Price:         65.00,
-			OriginalPrice: 70.00,
-			Description:   "All Coca-Cola taste, zero sugar. Zero calories.",
-			Image:         "https://i.postimg.cc/R0FS0gwP/zero.png",
-			Rating:        4.5,
-			Reviews:       760,
-			Category:      "Diet Drinks",
-			Volume:        "500ml",
-			Unit:          "single",
-			Tags:          `["zero-sugar", "diet", "caffeine-free", "calorie-free"]`,
+			Name:        "Coca-Cola Zero Sugar",
+			Brand:       "Coke",
+			Description: "All Coca-Cola taste, zero sugar. Zero calories.",
+			Image:       "https://i.postimg.cc/R0FS0gwP/zero.png",
+			Category:    "Diet Drinks",
+			Tags:        []string{"zero-sugar", "diet", "caffeine-free", "calorie-free"},
+			Variants: []models.ProductVariant{
+				{Name: "500ml", SKU: "COKE-ZERO-500", Volume: "500ml", Unit: "single", PackSize: 1, Price: 65.00, OriginalPrice: 70.00},
+			},
 		},
 		{
-			Name:          "Sprite Zero Sugar 1 Litre",
-			Brand:         "Sprite",
-			Price:         115.00,
-			OriginalPrice: 125.00,
-			Description:   "Great Sprite taste with zero sugar and zero calories.",
-			Image:         "https://i.postimg.cc/Vk4s1x0Z/spritezero.png",
-			Rating:        4.3,
-			Reviews:       420,
-			Category:      "Diet Drinks",
-			Volume:        "1L",
-			Unit:          "single",
-			Tags:          `["zero-sugar", "diet", "caffeine-free", "calorie-free", "large"]`,
+			Name:        "Sprite Zero Sugar",
+			Brand:       "Sprite",
+			Description: "Great Sprite taste with zero sugar and zero calories.",
+			Image:       "https://i.postimg.cc/Vk4s1x0Z/spritezero.png",
+			Category:    "Diet Drinks",
+			Tags:        []string{"zero-sugar", "diet", "caffeine-free", "calorie-free"},
+			Variants: []models.ProductVariant{
+				{Name: "1 Litre", SKU: "SPRITE-ZERO-1L", Volume: "1L", Unit: "single", PackSize: 1, Price: 115.00, OriginalPrice: 125.00},
+			},
 		},
 		{
-			Name:          "Fanta Pineapple 500ml",
-			Brand:         "Fanta",
-			Price:         60.00,
-			OriginalPrice: 65.00,
-			Description:   "Tropical pineapple flavor. Sweet and refreshing.",
-			Image:         "https://i.postimg.cc/CLGLrBF4/pine.webp",
-			Rating:        4.4,
-			Reviews:       540,
-			Category:      "Soft Drinks",
-			Volume:        "500ml",
-			Unit:          "single",
-			Tags:          `["tropical", "pineapple", "fruity", "exotic"]`,
+			Name:        "Fanta Pineapple",
+			Brand:       "Fanta",
+			Description: "Tropical pineapple flavor. Sweet and refreshing.",
+			Image:       "https://i.postimg.cc/CLGLrBF4/pine.webp",
+			Category:    "Soft Drinks",
+			Tags:        []string{"tropical", "pineapple", "fruity", "exotic"},
+			Variants: []models.ProductVariant{
+				{Name: "500ml", SKU: "FANTA-PINE-500", Volume: "500ml", Unit: "single", PackSize: 1, Price: 60.00, OriginalPrice: 65.00},
+			},
 		},
 		{
-			Name:          "Coca-Cola Vanilla 500ml",
-			Brand:         "Coke",
-			Price:         70.00,
-			OriginalPrice: 75.00,
-			Description:   "Classic Coca-Cola with smooth vanilla twist. Limited edition.",
-			Image:         "https://i.postimg.cc/mgVZRv1m/vani.png",
-			Rating:        4.9,
-			Reviews:       890,
-			Category:      "Special Editions",
-			Volume:        "500ml",
-			Unit:          "single",
-			Tags:          `["vanilla", "limited-edition", "special", "flavored"]`,
+			Name:        "Coca-Cola Vanilla",
+			Brand:       "Coke",
+			Description: "Classic Coca-Cola with smooth vanilla twist. Limited edition.",
+			Image:       "https://i.postimg.cc/mgVZRv1m/vani.png",
+			Category:    "Special Editions",
+			Tags:        []string{"vanilla", "limited-edition", "special", "flavored"},
+			Variants: []models.ProductVariant{
+				{Name: "500ml", SKU: "COKE-VAN-500", Volume: "500ml", Unit: "single", PackSize: 1, Price: 70.00, OriginalPrice: 75.00},
+			},
 		},
 	}
 
-	for _, product := range products {
-		var existingProduct models.Product
-		if err := db.DB.Where("name = ?", product.Name).First(&existingProduct).Error; err != nil {
-			if err := db.DB.Create(&product).Error; err != nil {
-				log.Printf("Failed to create product %s: %v", product.Name, err)
-			} else {
-				fmt.Printf("Created product: %s\n", product.Name)
+	for _, seed := range products {
+		var category models.Category
+		db.DB.Where(models.Category{Slug: utils.Slugify(seed.Category)}).
+			Attrs(models.Category{Name: seed.Category}).
+			FirstOrCreate(&category)
+
+		tags := []models.Tag{}
+		for _, name := range seed.Tags {
+			var tag models.Tag
+			db.DB.Where(models.Tag{Slug: utils.Slugify(name)}).Attrs(models.Tag{Name: name}).FirstOrCreate(&tag)
+			tags = append(tags, tag)
+		}
+
+		// The product shows its cheapest variant's price
+		cheapest := seed.Variants[0]
+		variantText := []string{}
+		for i, variant := range seed.Variants {
+			seed.Variants[i].Status = "active"
+			if variant.Price < cheapest.Price {
+				cheapest = variant
 			}
+			variantText = append(variantText, variant.Name, variant.Volume, variant.SKU)
+		}
+
+		product := models.Product{
+			Name:          seed.Name,
+			Brand:         seed.Brand,
+			Price:         cheapest.Price,
+			OriginalPrice: cheapest.OriginalPrice,
+			Description:   seed.Description,
+			Image:         seed.Image,
+			CategoryID:    &category.ID,
+			Variants:      seed.Variants,
+			Tags:          tags,
+			TagNames:      strings.Join(seed.Tags, " "),
+			VariantText:   strings.Join(variantText, " "),
+		}
+		if err := db.DB.Omit("Tags.*").Create(&product).Error; err != nil {
+			log.Printf("Failed to create product %s: %v", product.Name, err)
 		} else {
-			fmt.Printf("Product already exists: %s\n", existingProduct.Name)
+			fmt.Printf("Created product: %s (%d variants)\n", product.Name, len(product.Variants))
+			// Starts each variant's price history, as creating it through the API does
+			for _, variant := range product.Variants {
+				if err := db.DB.Create(&models.PriceChange{
+					VariantID:     variant.ID,
+					Price:         variant.Price,
+					OriginalPrice: variant.OriginalPrice,
+					Source:        "created",
+					EffectiveAt:   time.Now(),
+				}).Error; err != nil {
+					log.Printf("Failed to record the price of %s: %v", variant.SKU, err)
+				}
+			}
 		}
 	}
 }
@@ -271,16 +284,16 @@ func seedBranchInventory() {
 		return
 	}
 
-	var products []models.Product
-	if err := db.DB.Find(&products).Error; err != nil {
-		log.Printf("Failed to fetch products: %v", err)
+	var variants []models.ProductVariant
+	if err := db.DB.Preload("Product").Find(&variants).Error; err != nil {
+		log.Printf("Failed to fetch product variants: %v", err)
 		return
 	}
 
 	for _, branch := range branches {
-		for _, product := range products {
+		for _, variant := range variants {
 			var inventory models.BranchInventory
-			if err := db.DB.Where("branch_id = ? AND product_id = ?", branch.ID, product.ID).First(&inventory).Error; err != nil {
+			if err := db.DB.Where("branch_id = ? AND variant_id = ?", branch.ID, variant.ID).First(&inventory).Error; err != nil {
 				// Set initial stock levels
 				initialStock := 100
 				if branch.Name != "Nairobi" {
@@ -289,37 +302,82 @@ func seedBranchInventory() {
 
 				inventory = models.BranchInventory{
 					BranchID:  branch.ID,
-					ProductID: product.ID,
+					ProductID: variant.ProductID,
+					VariantID: variant.ID,
 					Quantity:  initialStock,
 				}
 
 				if err := db.DB.Create(&inventory).Error; err != nil {
-					log.Printf("Failed to create inventory for branch %s, product %s: %v", branch.Name, product.Name, err)
+					log.Printf("Failed to create inventory for branch %s, variant %s: %v", branch.Name, variant.SKU, err)
 				} else {
-					fmt.Printf("Created inventory: %s - %s (Qty: %d)\n", branch.Name, product.Name, initialStock)
+					fmt.Printf("Created inventory: %s - %s %s (Qty: %d)\n", branch.Name, variant.Product.Name, variant.Name, initialStock)
 				}
 			}
 		}
 	}
 }
 
-func seedAdminUser() {
-	var existingUser models.User
-	if err := db.DB.Where("email = ?", "admin@drinx.com").First(&existingUser).Error; err != nil {
-		admin := models.User{
-			Name:     "System Administrator",
-			Email:    "admin@drinx.com",
-			Phone:    "+254 700 000 000",
-			Password: "$2a$10$92IXUNpkjO0rOQ5byMi.Ye4oKoEa3Ro9llC/.og/at2.uheWG/igi", // password: "password"
-			Role:     "admin",
-		}
+var (
+	adminEmail    = flag.String("admin-email", "", "email of the first admin account (or BOOTSTRAP_ADMIN_EMAIL)")
+	adminName     = flag.String("admin-name", "", "name of the first admin account (or BOOTSTRAP_ADMIN_NAME)")
+	adminPhone    = flag.String("admin-phone", "", "phone of the first admin account (or BOOTSTRAP_ADMIN_PHONE)")
+	adminPassword = flag.String("admin-password", "", "password of the first admin account (prefer BOOTSTRAP_ADMIN_PASSWORD, which stays out of shell history)")
+)
 
-		if err := db.DB.Create(&admin).Error; err != nil {
-			log.Printf("Failed to create admin user: %v", err)
-		} else {
-			fmt.Printf("Created admin user: %s (password: password)\n", admin.Email)
-		}
+func flagOrEnv(value *string, env string) string {
+	if *value != "" {
+		return *value
+	}
+	return os.Getenv(env)
+}
+
+// bootstrapAdminUser creates the first admin account on a fresh install from
+// the -admin-* flags or BOOTSTRAP_ADMIN_* environment variables. It does
+// nothing once an admin exists; further staff are invited through the API.
+func bootstrapAdminUser() {
+	var admins int64
+	db.DB.Model(&models.User{}).Where("role = ?", "admin").Count(&admins)
+	if admins > 0 {
+		fmt.Println("Admin account already exists, skipping bootstrap")
+		return
+	}
+
+	email := flagOrEnv(adminEmail, "BOOTSTRAP_ADMIN_EMAIL")
+	phone := flagOrEnv(adminPhone, "BOOTSTRAP_ADMIN_PHONE")
+	password := flagOrEnv(adminPassword, "BOOTSTRAP_ADMIN_PASSWORD")
+	name := flagOrEnv(adminName, "BOOTSTRAP_ADMIN_NAME")
+	if name == "" {
+		name = "System Administrator"
+	}
+
+	if email == "" || phone == "" || password == "" {
+		fmt.Println("No admin account exists. Re-run with -admin-email, -admin-phone and -admin-password",
+			"(or BOOTSTRAP_ADMIN_EMAIL, BOOTSTRAP_ADMIN_PHONE and BOOTSTRAP_ADMIN_PASSWORD) to create one.")
+		return
+	}
+
+	if len(password) < 8 {
+		log.Printf("Failed to create admin user: password must be at least 8 characters")
+		return
+	}
+
+	normalizedPhone, err := utils.NormalizePhone(phone)
+	if err != nil {
+		log.Printf("Failed to create admin user: %v", err)
+		return
+	}
+
+	admin := models.User{
+		Name:     name,
+		Email:    email,
+		Phone:    normalizedPhone,
+		Password: utils.HashPassword(password),
+		Role:     "admin",
+	}
+
+	if err := db.DB.Create(&admin).Error; err != nil {
+		log.Printf("Failed to create admin user: %v", err)
 	} else {
-		fmt.Printf("Admin user already exists: %s\n", existingUser.Email)
+		fmt.Printf("Created admin user: %s\n", admin.Email)
 	}
 }
@@ -0,0 +1,21 @@
+// account activity model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// AccountActivity is a security-relevant event on a user's account, such as
+// a login or a password change, shown to the user in their activity feed.
+type AccountActivity struct {
+	gorm.Model
+	ID        string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    string    `gorm:"type:uuid;not null;index"`
+	User      User      `gorm:"foreignKey:UserID"`
+	Event     string    `gorm:"type:varchar(50);not null"` // e.g. 'login', 'password_changed', 'email_changed'
+	Details   string    `gorm:"type:varchar(255)"`
+	IPAddress string    `gorm:"type:varchar(45)"`
+	UserAgent string    `gorm:"type:varchar(255)"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
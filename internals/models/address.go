@@ -0,0 +1,26 @@
+// address model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// Address is a delivery address saved by a customer. At most one per user is
+// the default.
+type Address struct {
+	gorm.Model
+	ID            string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID        string    `gorm:"type:uuid;not null;index"`
+	User          User      `gorm:"foreignKey:UserID"`
+	Label         string    `gorm:"type:varchar(50);not null"` // e.g. 'Home', 'Office'
+	RecipientName string    `gorm:"type:varchar(100);not null"`
+	Phone         string    `gorm:"type:varchar(20);not null"`
+	Line1         string    `gorm:"type:varchar(255);not null"`
+	Line2         string    `gorm:"type:varchar(255)"`
+	City          string    `gorm:"type:varchar(100);not null"`
+	Notes         string    `gorm:"type:varchar(255)"` // Directions for the rider
+	IsDefault     bool      `gorm:"not null;default:false"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
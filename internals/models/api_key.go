@@ -0,0 +1,27 @@
+// api key model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// APIKey lets a machine client such as the warehouse scanner or BI tool call
+// admin routes without a user login. Only the key's hash is stored.
+type APIKey struct {
+	gorm.Model
+	ID            string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Name          string     `gorm:"type:varchar(100);not null"`
+	Prefix        string     `gorm:"type:varchar(16);not null"` // Start of the key, shown so it can be recognised
+	KeyHash       string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes        string     `gorm:"type:text;not null;default:'[]'"` // JSON array of permissions
+	Branches      []Branch   `gorm:"many2many:api_key_branches"`      // Empty means every branch
+	ExpiresAt     *time.Time
+	LastUsedAt    *time.Time
+	LastUsedIP    string     `gorm:"type:varchar(45)"`
+	RevokedAt     *time.Time
+	CreatedBy     string     `gorm:"type:uuid;not null"`
+	CreatedByUser User       `gorm:"foreignKey:CreatedBy"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime"`
+}
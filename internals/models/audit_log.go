@@ -0,0 +1,22 @@
+// audit log model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// AuditLog records an edit to a catalogue or branch record: which fields
+// changed, from what to what, and who changed them.
+type AuditLog struct {
+	gorm.Model
+	ID            string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	EntityType    string    `gorm:"type:varchar(30);not null;index:idx_audit_logs_entity;check:entity_type IN ('product', 'branch')"`
+	EntityID      string    `gorm:"type:varchar(50);not null;index:idx_audit_logs_entity"`
+	Version       int       `gorm:"not null"` // The record's version after the change
+	Changes       string    `gorm:"type:jsonb;not null"` // {"field": {"from": ..., "to": ...}}
+	ChangedBy     string    `gorm:"type:uuid;not null"`
+	ChangedByUser User      `gorm:"foreignKey:ChangedBy"`
+	IPAddress     string    `gorm:"type:varchar(45)"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
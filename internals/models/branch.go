@@ -9,11 +9,24 @@ import (
 type Branch struct {
 	gorm.Model
 	ID           string    `gorm:"type:varchar(50);primaryKey"` // e.g., 'branch-nairobi'
-	Name         string    `gorm:"type:varchar(50);not null;check:name IN ('Nairobi', 'Kisumu', 'Mombasa', 'Nakuru', 'Eldoret')"`
+	Name         string    `gorm:"type:varchar(50);not null"` // One of the permitted BranchLocations; the migration adds the foreign key
 	IsHeadquarter bool      `gorm:"default:false"`
 	Address      string    `gorm:"type:varchar(255);not null"`
 	Phone        string    `gorm:"type:varchar(20);not null"`
 	Status       string    `gorm:"type:varchar(20);not null;default:'active';check:status IN ('active', 'inactive')"`
+	Version      int       `gorm:"not null;default:1"` // Incremented by every edit; sent as the ETag
 	CreatedAt    time.Time `gorm:"autoCreateTime"`
 	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
 }
+
+// BranchLocation is a town where the business is permitted to open a branch.
+// Branch.Name must be one of these.
+type BranchLocation struct {
+	gorm.Model
+	ID        string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Name      string    `gorm:"type:varchar(50);not null;uniqueIndex"` // e.g. 'Nairobi', 'Thika'
+	Region    string    `gorm:"type:varchar(50)"`
+	Status    string    `gorm:"type:varchar(20);not null;default:'active';check:status IN ('active', 'inactive')"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,19 @@
+// brand model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// Brand is a drinks brand the stores sell. Products and order items refer to
+// it by name, so renaming a brand cascades to them.
+type Brand struct {
+	gorm.Model
+	ID          string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Name        string    `gorm:"type:varchar(50);not null;uniqueIndex"` // e.g. 'Coke', 'Minute Maid'
+	Description string    `gorm:"type:text"`
+	Status      string    `gorm:"type:varchar(20);not null;default:'active';check:status IN ('active', 'inactive')"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
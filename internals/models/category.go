@@ -0,0 +1,33 @@
+// category and tag models
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// Category groups products for browsing. Categories form a tree through
+// ParentID; top-level categories have none.
+type Category struct {
+	gorm.Model
+	ID          string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Name        string    `gorm:"type:varchar(50);not null"`
+	Slug        string    `gorm:"type:varchar(60);not null;uniqueIndex:idx_categories_slug,where:deleted_at IS NULL"`
+	Description string    `gorm:"type:text"`
+	ParentID    *string   `gorm:"type:uuid;index"`
+	Parent      *Category `gorm:"foreignKey:ParentID"`
+	SortOrder   int       `gorm:"not null;default:0"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
+
+// Tag is a free-form label on products (e.g. 'zero-sugar', 'crate'),
+// linked through the product_tags join table.
+type Tag struct {
+	gorm.Model
+	ID        string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Name      string    `gorm:"type:varchar(50);not null"`
+	Slug      string    `gorm:"type:varchar(60);not null;uniqueIndex:idx_tags_slug,where:deleted_at IS NULL"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
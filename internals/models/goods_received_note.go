@@ -0,0 +1,36 @@
+// goods received note model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+type GoodsReceivedNote struct {
+	gorm.Model
+	ID              string        `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	PurchaseOrderID string        `gorm:"type:uuid;not null;index"`
+	PurchaseOrder   PurchaseOrder `gorm:"foreignKey:PurchaseOrderID"`
+	BranchID        string        `gorm:"type:varchar(50);not null"`
+	ReceivedBy      string        `gorm:"type:uuid;not null"`
+	ReceivedByUser  User          `gorm:"foreignKey:ReceivedBy"`
+	DeliveryNoteRef string        `gorm:"type:varchar(100)"` // Supplier's delivery note number
+	Notes           string        `gorm:"type:text"`
+	CreatedAt       time.Time     `gorm:"autoCreateTime"`
+	Lines           []GoodsReceivedLine `gorm:"foreignKey:GoodsReceivedNoteID"`
+}
+
+type GoodsReceivedLine struct {
+	gorm.Model
+	ID                  string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	GoodsReceivedNoteID string    `gorm:"type:uuid;not null;index"`
+	PurchaseOrderLineID string    `gorm:"type:uuid;not null"`
+	ProductID           string    `gorm:"type:uuid;not null"`
+	Product             Product   `gorm:"foreignKey:ProductID"`
+	VariantID           string    `gorm:"type:uuid;not null"`
+	Variant             ProductVariant `gorm:"foreignKey:VariantID"`
+	Quantity            int       `gorm:"not null"`
+	UnitCost            float64   `gorm:"not null"` // Actual cost per unit on this delivery
+	RestockLogID        string    `gorm:"type:uuid;not null"`
+	CreatedAt           time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,41 @@
+// inventory batch model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// InventoryBatch is a lot of stock received in a single restock. The sum of
+// QuantityRemaining across a branch's batches makes up the batched part of
+// BranchInventory.Quantity; stock that predates batch tracking is unbatched.
+type InventoryBatch struct {
+	gorm.Model
+	ID                string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	BranchID          string     `gorm:"type:varchar(50);not null;index:idx_batch_branch_variant"`
+	Branch            Branch     `gorm:"foreignKey:BranchID"`
+	ProductID         string     `gorm:"type:uuid;not null"`
+	Product           Product    `gorm:"foreignKey:ProductID"`
+	VariantID         string     `gorm:"type:uuid;not null;index:idx_batch_branch_variant"`
+	Variant           ProductVariant `gorm:"foreignKey:VariantID"`
+	BatchNumber       string     `gorm:"type:varchar(100)"`
+	ExpiryDate        *time.Time `gorm:"index"`
+	QuantityReceived  int        `gorm:"not null"`
+	QuantityRemaining int        `gorm:"not null"`
+	UnitCost          *float64
+	RestockLogID      string     `gorm:"type:uuid;not null"`
+	Status            string     `gorm:"type:varchar(20);not null;default:'active';check:status IN ('active', 'depleted', 'written_off')"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime"`
+}
+
+// OrderItemBatch records which batches an order item was picked from.
+type OrderItemBatch struct {
+	gorm.Model
+	ID          string         `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	OrderItemID string         `gorm:"type:uuid;not null;index"`
+	BatchID     string         `gorm:"type:uuid;not null;index"`
+	Batch       InventoryBatch `gorm:"foreignKey:BatchID"`
+	Quantity    int            `gorm:"not null"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,21 @@
+// login throttle model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// LoginThrottle counts recent failed logins for one account (by email) or one
+// client IP, and holds the lockout when there have been too many.
+type LoginThrottle struct {
+	gorm.Model
+	ID           string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Scope        string     `gorm:"type:varchar(10);not null;uniqueIndex:idx_login_throttles_scope_key;check:scope IN ('account', 'ip')"`
+	Key          string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_login_throttles_scope_key"` // Lower-cased email or IP address
+	FailedCount  int        `gorm:"not null;default:0"`
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+	CreatedAt    time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime"`
+}
@@ -17,6 +17,8 @@ type Order struct {
 	PaymentStatus        string    `gorm:"type:varchar(20);not null;default:'pending';check:payment_status IN ('pending', 'completed', 'failed')"`
 	PaymentMethod        string    `gorm:"type:varchar(20);not null;default:'mpesa';check:payment_method IN ('mpesa')"`
 	MpesaTransactionID   *string   `gorm:"type:varchar(255)"`
+	AddressID            *string   `gorm:"type:uuid"`
+	DeliveryAddress      string    `gorm:"type:varchar(500)"` // Copy of the address at order time, kept if the address is later edited
 	OrderStatus          string    `gorm:"type:varchar(20);not null;default:'processing';check:order_status IN ('processing', 'completed', 'cancelled')"`
 	CreatedAt            time.Time `gorm:"autoCreateTime"`
 	UpdatedAt            time.Time `gorm:"autoUpdateTime"`
@@ -31,9 +33,14 @@ type OrderItem struct {
 	Order       Order   `gorm:"foreignKey:OrderID"`
 	ProductID   string  `gorm:"type:uuid;not null"`
 	Product     Product `gorm:"foreignKey:ProductID"`
-	ProductBrand string `gorm:"type:varchar(50);not null;check:product_brand IN ('Coke', 'Fanta', 'Sprite')"`
+	VariantID   string  `gorm:"type:uuid;not null"`
+	Variant     ProductVariant `gorm:"foreignKey:VariantID"`
+	ProductBrand string `gorm:"type:varchar(50);not null"`
+	BrandRecord *Brand  `gorm:"foreignKey:ProductBrand;references:Name;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"` // Holds the foreign key on product_brand
 	Quantity    int     `gorm:"not null"`
 	Price       float64 `gorm:"not null"`
 	Subtotal    float64 `gorm:"not null"`
+	UnitCost    float64 `gorm:"not null;default:0"` // Branch weighted-average cost at sale time
+	CostOfGoods float64 `gorm:"not null;default:0"` // UnitCost x Quantity
 	CreatedAt   time.Time `gorm:"autoCreateTime"`
 }
@@ -0,0 +1,46 @@
+// price history models
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// PriceChange records a variant's prices from EffectiveAt until its next
+// change, so past prices can be looked up.
+type PriceChange struct {
+	gorm.Model
+	ID                     string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	VariantID              string    `gorm:"type:uuid;not null;index:idx_price_changes_variant_effective"`
+	Price                  float64   `gorm:"not null"`
+	OriginalPrice          float64   `gorm:"not null"`
+	PreviousPrice          *float64  // Nil for a variant's first price
+	PreviousOriginalPrice  *float64
+	Source                 string    `gorm:"type:varchar(20);not null;check:source IN ('created', 'manual', 'scheduled', 'migrated')"`
+	ChangedBy              *string   `gorm:"type:uuid"` // Nil for prices carried over by the migration
+	ChangedByUser          *User     `gorm:"foreignKey:ChangedBy"`
+	ScheduledPriceChangeID *string   `gorm:"type:uuid"`
+	EffectiveAt            time.Time `gorm:"not null;index:idx_price_changes_variant_effective"`
+	CreatedAt              time.Time `gorm:"autoCreateTime"`
+}
+
+// ScheduledPriceChange is a price change to be applied to a variant at
+// EffectiveAt by the price scheduler.
+type ScheduledPriceChange struct {
+	gorm.Model
+	ID             string         `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	VariantID      string         `gorm:"type:uuid;not null;index"`
+	Variant        ProductVariant `gorm:"foreignKey:VariantID"`
+	Price          float64        `gorm:"not null"`
+	OriginalPrice  *float64       // Keeps the variant's original price when nil
+	EffectiveAt    time.Time      `gorm:"not null;index"`
+	Status         string         `gorm:"type:varchar(20);not null;default:'pending';check:status IN ('pending', 'applied', 'cancelled')"`
+	Note           string         `gorm:"type:varchar(255)"`
+	CreatedBy      string         `gorm:"type:uuid;not null"`
+	CreatedByUser  User           `gorm:"foreignKey:CreatedBy"`
+	AppliedAt      *time.Time
+	CancelledBy    *string        `gorm:"type:uuid"` // Nil when cancelled because the variant was deleted
+	CancelledAt    *time.Time
+	CreatedAt      time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime"`
+}
@@ -10,17 +10,35 @@ type Product struct {
 	gorm.Model
 	ID            string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
 	Name          string    `gorm:"type:varchar(100);not null"`
-	Brand         string    `gorm:"type:varchar(50);not null;check:brand IN ('Coke', 'Fanta', 'Sprite')"`
+	Brand         string    `gorm:"type:varchar(50);not null;index"`
+	BrandRecord   *Brand    `gorm:"foreignKey:Brand;references:Name;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"` // Holds the foreign key on brand
 	Description   string    `gorm:"type:text"`
-	Price         float64   `gorm:"not null"` // Current price in KSh
-	OriginalPrice float64   `gorm:"not null"` // Original price in KSh
-	Image         string    `gorm:"type:varchar(255)"`
+	Price         float64   `gorm:"not null"` // Lowest active variant price in KSh, kept in step with the variants
+	OriginalPrice float64   `gorm:"not null"` // Original price of that variant in KSh
+	Image         string    `gorm:"type:varchar(500)"` // URL of the first uploaded image, or an external image URL
+	Images        []ProductImage `gorm:"foreignKey:ProductID"`
 	Rating        float64   `gorm:"default:0"`
 	Reviews       int       `gorm:"default:0"`
-	Category      string    `gorm:"type:varchar(50);not null;default:'Soft Drinks'"`
-	Volume        string    `gorm:"type:varchar(20);default:'500ml'"`
-	Unit          string    `gorm:"type:varchar(20);default:'single'"`
-	Tags          string    `gorm:"type:json;default:'[]'"` // JSON array of tags
+	CategoryID    *string   `gorm:"type:uuid;index"`
+	Category      *Category `gorm:"foreignKey:CategoryID"`
+	Variants      []ProductVariant `gorm:"foreignKey:ProductID"`
+	Tags          []Tag     `gorm:"many2many:product_tags"`
+	TagNames      string    `gorm:"type:text;not null;default:''" json:"-"` // Copy of the tag names for the search index
+	VariantText   string    `gorm:"type:text;not null;default:''" json:"-"` // Copy of the variant names, volumes, SKUs and barcodes for the search index
+	Version       int       `gorm:"not null;default:1"` // Incremented by every edit; sent as the ETag
 	CreatedAt     time.Time `gorm:"autoCreateTime"`
 	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
 }
+
+// ProductSearchVector is the full-text document used by product search. The
+// migration indexes this exact expression, so the two must stay identical; a
+// change needs a new index name in createSearchIndexes.
+// Everything uses the 'simple' configuration, as search terms are matched as
+// unstemmed prefixes.
+const ProductSearchVector = `(setweight(to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(brand, '')), 'A') || ` +
+	`setweight(to_tsvector('simple', coalesce(variant_text, '') || ' ' || coalesce(tag_names, '')), 'B') || ` +
+	`setweight(to_tsvector('simple', coalesce(description, '')), 'C'))`
+
+// ProductSearchText is compared against search terms by trigram similarity so
+// that misspelt terms still match. The migration indexes it with gin_trgm_ops.
+const ProductSearchText = `lower(coalesce(name, '') || ' ' || coalesce(brand, '') || ' ' || coalesce(variant_text, '') || ' ' || coalesce(tag_names, ''))`
@@ -0,0 +1,29 @@
+// product image model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// ProductImage is an uploaded product photo with its generated thumbnails.
+// A product's images are shown in Position order; the first is also copied
+// to Product.Image.
+type ProductImage struct {
+	gorm.Model
+	ID           string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	ProductID    string    `gorm:"type:uuid;not null;index"`
+	Position     int       `gorm:"not null;default:0"`
+	URL          string    `gorm:"type:varchar(500);not null"`
+	MediumURL    string    `gorm:"type:varchar(500);not null"`
+	ThumbnailURL string    `gorm:"type:varchar(500);not null"`
+	AltText      string    `gorm:"type:varchar(255)"`
+	ContentType  string    `gorm:"type:varchar(50);not null"`
+	Size         int64     `gorm:"not null"` // Bytes of the original upload
+	Width        int       `gorm:"not null"`
+	Height       int       `gorm:"not null"`
+	StorageKeys  string    `gorm:"type:text;not null" json:"-"` // Space-separated keys of the stored files
+	UploadedBy   string    `gorm:"type:uuid;not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,28 @@
+// product variant model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// ProductVariant is one sellable size or pack of a product, e.g. the 500ml
+// bottle and the crate of 24 of "Coca-Cola Original". Stock, prices, orders
+// and purchasing are all per variant.
+type ProductVariant struct {
+	gorm.Model
+	ID            string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	ProductID     string    `gorm:"type:uuid;not null;index"`
+	Product       *Product  `gorm:"foreignKey:ProductID"`
+	Name          string    `gorm:"type:varchar(100);not null"` // e.g. '500ml', 'Crate of 24'
+	SKU           string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_product_variants_sku,where:deleted_at IS NULL"`
+	Barcode       *string   `gorm:"type:varchar(14);uniqueIndex:idx_product_variants_barcode,where:deleted_at IS NULL"` // EAN-13, EAN-8 or UPC-A
+	Volume        string    `gorm:"type:varchar(20);not null;default:'500ml'"` // Volume of one unit
+	Unit          string    `gorm:"type:varchar(20);not null;default:'single'"` // e.g. 'single', 'crate'
+	PackSize      int       `gorm:"not null;default:1"` // Units in the pack
+	Price         float64   `gorm:"not null"` // Current price in KSh
+	OriginalPrice float64   `gorm:"not null"` // Original price in KSh
+	Status        string    `gorm:"type:varchar(20);not null;default:'active';check:status IN ('active', 'inactive')"`
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,39 @@
+// purchase order model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+type PurchaseOrder struct {
+	gorm.Model
+	ID                   string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	SupplierID           string     `gorm:"type:uuid;not null"`
+	Supplier             Supplier   `gorm:"foreignKey:SupplierID"`
+	BranchID             string     `gorm:"type:varchar(50);not null"`
+	Branch               Branch     `gorm:"foreignKey:BranchID"`
+	Status               string     `gorm:"type:varchar(20);not null;default:'open';check:status IN ('open', 'partially_received', 'received', 'cancelled')"`
+	ExpectedDeliveryDate *time.Time
+	Notes                string     `gorm:"type:text"`
+	CreatedBy            string     `gorm:"type:uuid;not null"`
+	CreatedByUser        User       `gorm:"foreignKey:CreatedBy"`
+	CreatedAt            time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt            time.Time  `gorm:"autoUpdateTime"`
+	Lines                []PurchaseOrderLine `gorm:"foreignKey:PurchaseOrderID"`
+}
+
+type PurchaseOrderLine struct {
+	gorm.Model
+	ID               string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	PurchaseOrderID  string    `gorm:"type:uuid;not null;index"`
+	ProductID        string    `gorm:"type:uuid;not null"`
+	Product          Product   `gorm:"foreignKey:ProductID"`
+	VariantID        string    `gorm:"type:uuid;not null"`
+	Variant          ProductVariant `gorm:"foreignKey:VariantID"`
+	QuantityOrdered  int       `gorm:"not null"`
+	QuantityReceived int       `gorm:"not null;default:0"`
+	UnitCost         float64   `gorm:"not null"` // Agreed cost per unit in KSh
+	CreatedAt        time.Time `gorm:"autoCreateTime"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime"`
+}
@@ -13,10 +13,23 @@ type RestockLog struct {
 	Branch           Branch    `gorm:"foreignKey:BranchID"`
 	ProductID        string    `gorm:"type:uuid;not null"`
 	Product          Product  `gorm:"foreignKey:ProductID"`
-	QuantityAdded    int       `gorm:"not null"`
+	VariantID        string    `gorm:"type:uuid;not null"`
+	Variant          ProductVariant `gorm:"foreignKey:VariantID"`
+	MovementType     string    `gorm:"type:varchar(20);not null;default:'restock';check:movement_type IN ('restock', 'write_off')"`
+	QuantityAdded    int       `gorm:"not null"` // Negative for write-offs
 	PreviousQuantity int       `gorm:"not null"`
 	NewQuantity      int       `gorm:"not null"`
-	RestockedBy      string    `gorm:"type:uuid;not null"`
+	UnitCost         *float64  // Cost per unit in KSh, when known
+	AverageCost      float64   `gorm:"not null;default:0"` // Branch weighted-average cost after this restock
+	PurchaseOrderID  *string   `gorm:"type:uuid;index"`
+	PurchaseOrder    *PurchaseOrder `gorm:"foreignKey:PurchaseOrderID"`
+	GoodsReceivedNoteID *string `gorm:"type:uuid"`
+	BatchNumber      string    `gorm:"type:varchar(100)"`
+	ExpiryDate       *time.Time
+	RestockRequestID *string   `gorm:"type:uuid"`
+	RequestedBy      *string   `gorm:"type:uuid"` // Staff member who asked for the stock, when approved from a request
+	RequestedByUser  *User     `gorm:"foreignKey:RequestedBy"`
+	RestockedBy      string    `gorm:"type:uuid;not null"` // User who applied (or approved) the restock
 	RestockedByUser  User      `gorm:"foreignKey:RestockedBy"`
 	CreatedAt        time.Time `gorm:"autoCreateTime"`
 }
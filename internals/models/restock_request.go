@@ -0,0 +1,42 @@
+// restock request model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// RestockRequest is a branch staff member's request for stock, applied to
+// BranchInventory only once an HQ admin approves it.
+type RestockRequest struct {
+	gorm.Model
+	ID              string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	BranchID        string     `gorm:"type:varchar(50);not null;index"`
+	Branch          Branch     `gorm:"foreignKey:BranchID"`
+	RequestedBy     string     `gorm:"type:uuid;not null"`
+	RequestedByUser User       `gorm:"foreignKey:RequestedBy"`
+	Status          string     `gorm:"type:varchar(20);not null;default:'pending';check:status IN ('pending', 'approved', 'rejected', 'cancelled')"`
+	Notes           string     `gorm:"type:text"`
+	ReviewedBy      *string    `gorm:"type:uuid"`
+	ReviewedByUser  *User      `gorm:"foreignKey:ReviewedBy"`
+	ReviewNotes     string     `gorm:"type:text"`
+	ReviewedAt      *time.Time
+	CreatedAt       time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `gorm:"autoUpdateTime"`
+	Lines           []RestockRequestLine `gorm:"foreignKey:RestockRequestID"`
+}
+
+type RestockRequestLine struct {
+	gorm.Model
+	ID                string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	RestockRequestID  string    `gorm:"type:uuid;not null;index"`
+	ProductID         string    `gorm:"type:uuid;not null"`
+	Product           Product   `gorm:"foreignKey:ProductID"`
+	VariantID         string    `gorm:"type:uuid;not null"`
+	Variant           ProductVariant `gorm:"foreignKey:VariantID"`
+	QuantityRequested int       `gorm:"not null"`
+	QuantityApproved  *int      // Set on approval; may differ from the request when amended
+	RestockLogID      *string   `gorm:"type:uuid"`
+	CreatedAt         time.Time `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+}
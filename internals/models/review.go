@@ -0,0 +1,29 @@
+// review model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// Review is a customer's star rating and comments on a product they bought.
+// Only approved reviews are shown and count towards Product.Rating and
+// Product.Reviews.
+type Review struct {
+	gorm.Model
+	ID             string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	ProductID      string     `gorm:"type:uuid;not null;uniqueIndex:idx_reviews_product_user,where:deleted_at IS NULL"`
+	Product        Product    `gorm:"foreignKey:ProductID"`
+	UserID         string     `gorm:"type:uuid;not null;uniqueIndex:idx_reviews_product_user,where:deleted_at IS NULL;index"`
+	User           User       `gorm:"foreignKey:UserID"`
+	OrderID        string     `gorm:"type:uuid;not null"` // A completed order containing the product
+	Rating         int        `gorm:"not null;check:rating BETWEEN 1 AND 5"`
+	Title          string     `gorm:"type:varchar(100)"`
+	Body           string     `gorm:"type:text"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'pending';check:status IN ('pending', 'approved', 'rejected')"`
+	ModeratedBy    *string    `gorm:"type:uuid"`
+	ModeratedAt    *time.Time
+	ModerationNote string     `gorm:"type:varchar(255)"` // Shown to the author when a review is rejected
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime"`
+}
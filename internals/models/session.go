@@ -0,0 +1,26 @@
+// session model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// Session is a signed-in device. Access tokens carry the session ID and are
+// rejected once the session is revoked; the refresh token is stored hashed
+// and rotated on every use.
+type Session struct {
+	gorm.Model
+	ID                string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID            string     `gorm:"type:uuid;not null;index"`
+	User              User       `gorm:"foreignKey:UserID"`
+	RefreshTokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	PreviousTokenHash *string    `gorm:"type:varchar(64);index"` // Last rotated-out token, used to detect reuse
+	UserAgent         string     `gorm:"type:varchar(255)"`
+	IPAddress         string     `gorm:"type:varchar(45)"`
+	ExpiresAt         time.Time  `gorm:"not null"`
+	LastUsedAt        time.Time  `gorm:"not null"`
+	RevokedAt         *time.Time `gorm:"index"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime"`
+}
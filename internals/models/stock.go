@@ -13,7 +13,10 @@ type BranchInventory struct {
 	Branch        Branch    `gorm:"foreignKey:BranchID"`
 	ProductID     string    `gorm:"type:uuid;not null"`
 	Product       Product   `gorm:"foreignKey:ProductID"`
+	VariantID     string    `gorm:"type:uuid;not null;index"`
+	Variant       ProductVariant `gorm:"foreignKey:VariantID"`
 	Quantity      int       `gorm:"not null;default:0"`
+	AverageCost   float64   `gorm:"not null;default:0"` // Weighted-average unit cost in KSh
 	LastRestocked time.Time `gorm:"autoCreateTime"`
 	CreatedAt     time.Time `gorm:"autoCreateTime"`
 	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
@@ -0,0 +1,20 @@
+// supplier model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+type Supplier struct {
+	gorm.Model
+	ID          string    `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Name        string    `gorm:"type:varchar(100);uniqueIndex:idx_suppliers_name,where:deleted_at IS NULL;not null"` // Names of deleted suppliers can be reused
+	ContactName string    `gorm:"type:varchar(100)"`
+	Email       string    `gorm:"type:varchar(100)"`
+	Phone       string    `gorm:"type:varchar(20);not null"`
+	Address     string    `gorm:"type:varchar(255)"`
+	Status      string    `gorm:"type:varchar(20);not null;default:'active';check:status IN ('active', 'inactive')"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,19 @@
+// two-factor recovery code model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// TwoFactorRecoveryCode is a single-use backup code for signing in when the
+// authenticator app is unavailable. Only its hash is stored.
+type TwoFactorRecoveryCode struct {
+	gorm.Model
+	ID        string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    string     `gorm:"type:uuid;not null;index"`
+	User      User       `gorm:"foreignKey:UserID"`
+	CodeHash  string     `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
@@ -12,8 +12,19 @@ type User struct {
 	Name      string    `gorm:"type:varchar(100);not null"`
 	Email     string    `gorm:"type:varchar(100);uniqueIndex;not null"`
 	Phone     string    `gorm:"type:varchar(20);not null"`
-	Password  string    `gorm:"type:varchar(255);not null"`
-	Role      string    `gorm:"type:varchar(50);not null;check:role IN ('customer', 'admin')"` // customer or admin
+	EmailVerifiedAt *time.Time
+	PhoneVerifiedAt *time.Time
+	Password  string    `gorm:"type:varchar(255);not null" json:"-"`
+	TOTPSecret         string `gorm:"type:varchar(64)" json:"-"` // Set at 2FA setup; active once TwoFactorEnabledAt is set
+	TOTPLastStep       int64  `json:"-"`                         // Last accepted TOTP time step, so a code cannot be replayed
+	TwoFactorEnabledAt *time.Time
+	DisabledAt            *time.Time // Disabled accounts cannot log in
+	PasswordResetRequired bool       `gorm:"not null;default:false"` // Set by an admin; login is refused until the password is reset
+	AnonymisedAt          *time.Time // Set when the customer deleted their account; personal data has been removed
+	Role      string    `gorm:"type:varchar(50);not null;check:role IN ('customer', 'cashier', 'branch_manager', 'admin')"` // see internals/permissions
+	Branches  []Branch  `gorm:"many2many:user_branches"` // Branches a cashier or branch manager works at
+	PreferredBranchID *string `gorm:"type:varchar(50)"` // Customer's usual branch for browsing and ordering
+	PreferredBranch   *Branch `gorm:"foreignKey:PreferredBranchID"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
 }
@@ -0,0 +1,24 @@
+// user token model
+package models
+
+import (
+	"time"
+	"gorm.io/gorm"
+)
+
+// UserToken is a single-use, time-limited secret sent to a user, such as an
+// email verification link, a password reset token or a login code. Only its
+// hash is stored.
+type UserToken struct {
+	gorm.Model
+	ID        string     `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    string     `gorm:"type:uuid;not null;index"`
+	User      User       `gorm:"foreignKey:UserID"`
+	Purpose   string     `gorm:"type:varchar(30);not null;check:purpose IN ('email_verification', 'phone_verification', 'password_reset', 'login_otp', 'two_factor_login')"`
+	TokenHash string     `gorm:"type:varchar(64);not null;index"`
+	Target    string     `gorm:"type:varchar(100);not null"` // Email or phone the token was sent to
+	Attempts  int        `gorm:"not null;default:0"`         // Failed attempts against short numeric codes
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
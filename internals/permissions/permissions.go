@@ -0,0 +1,104 @@
+// role and permission definitions
+package permissions
+
+import "os"
+
+const (
+	RoleCustomer      = "customer"
+	RoleCashier       = "cashier"
+	RoleBranchManager = "branch_manager"
+	RoleAdmin         = "admin" // HQ admin, unrestricted across branches
+)
+
+const (
+	ManageBranches        = "branches:manage"
+	ManageProducts        = "products:manage"
+	ViewInventory         = "inventory:view"
+	RestockInventory      = "inventory:restock"
+	WriteOffInventory     = "inventory:write_off"
+	RequestRestock        = "restock_requests:create"
+	ReviewRestockRequests = "restock_requests:review"
+	ManagePurchasing      = "purchasing:manage"
+	ViewOrders            = "orders:view"
+	ManageOrders          = "orders:manage"
+	ConfirmPayments       = "payments:confirm" // Mark an unpaid order as paid outside M-Pesa
+	ViewReports           = "reports:view"
+	ManageUsers           = "users:manage"
+	ModerateReviews       = "reviews:moderate"
+)
+
+var rolePermissions = map[string][]string{
+	RoleCustomer: {},
+	RoleCashier: {
+		ViewInventory,
+		RequestRestock,
+		ViewOrders,
+		ManageOrders,
+	},
+	RoleBranchManager: {
+		ViewInventory,
+		RestockInventory,
+		WriteOffInventory,
+		RequestRestock,
+		ViewOrders,
+		ManageOrders,
+		ConfirmPayments,
+		ViewReports,
+	},
+	RoleAdmin: {
+		ManageBranches,
+		ManageProducts,
+		ViewInventory,
+		RestockInventory,
+		WriteOffInventory,
+		RequestRestock,
+		ReviewRestockRequests,
+		ManagePurchasing,
+		ViewOrders,
+		ManageOrders,
+		ConfirmPayments,
+		ViewReports,
+		ManageUsers,
+		ModerateReviews,
+	},
+}
+
+// Has reports whether role grants permission.
+func Has(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ForRole returns the permissions granted to role.
+func ForRole(role string) []string {
+	return append([]string{}, rolePermissions[role]...)
+}
+
+// IsValidRole reports whether role is a known role.
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// IsValidPermission reports whether permission is a known permission.
+func IsValidPermission(permission string) bool {
+	// Admins hold every permission
+	return Has(RoleAdmin, permission)
+}
+
+// IsBranchScoped reports whether users with role only see the branches they
+// are assigned to.
+func IsBranchScoped(role string) bool {
+	return role == RoleCashier || role == RoleBranchManager
+}
+
+// RequiresTwoFactor reports whether users with role must have two-factor
+// authentication enabled before using permission-gated routes. This applies
+// to admins once REQUIRE_ADMIN_2FA is set to "true".
+func RequiresTwoFactor(role string) bool {
+	return role == RoleAdmin && os.Getenv("REQUIRE_ADMIN_2FA") == "true"
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ByteBenders-compScientists/smart-retail-backend/internals/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// Notification is a message to a user over email or SMS.
+type Notification struct {
+	Channel string `json:"channel"` // "email" or "sms"
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+}
+
+// Notifier delivers notifications to users. Implementations for real email
+// and SMS providers plug in through NewNotifier.
+type Notifier interface {
+	Send(n Notification) error
+}
+
+// NewNotifier returns the notifier selected by NOTIFIER. Only the local
+// "file" sink exists so far, which is also the default.
+func NewNotifier() Notifier {
+	switch os.Getenv("NOTIFIER") {
+	default:
+		path := os.Getenv("NOTIFICATIONS_FILE")
+		if path == "" {
+			path = filepath.Join("logs", "notifications.log")
+		}
+		return &FileNotifier{Path: path, Logger: utils.Logger}
+	}
+}
+
+// FileNotifier is a development sink that appends each notification as a
+// JSON line to a file and logs it, instead of sending anything.
+type FileNotifier struct {
+	Path   string
+	Logger *logrus.Logger
+}
+
+var fileNotifierMu sync.Mutex
+
+func (f *FileNotifier) Send(n Notification) error {
+	fileNotifierMu.Lock()
+	defer fileNotifierMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entry := struct {
+		Notification
+		SentAt time.Time `json:"sentAt"`
+	}{n, time.Now()}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	f.Logger.WithFields(logrus.Fields{
+		"channel": n.Channel,
+		"to":      n.To,
+		"subject": n.Subject,
+		"file":    f.Path,
+	}).Info("Notification written to local sink")
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import "os"
+
+// SMSProvider sends text messages. Real gateways (Africa's Talking, Twilio,
+// etc.) plug in through NewSMSProvider.
+type SMSProvider interface {
+	SendSMS(to, message string) error
+}
+
+// NewSMSProvider returns the provider selected by SMS_PROVIDER. Only the
+// local "stub" exists so far, which is also the default.
+func NewSMSProvider() SMSProvider {
+	switch os.Getenv("SMS_PROVIDER") {
+	default:
+		return &StubSMSProvider{Sink: NewNotifier()}
+	}
+}
+
+// StubSMSProvider hands messages to the local notification sink instead of
+// sending them, so codes can be read from the notifications file in development.
+type StubSMSProvider struct {
+	Sink Notifier
+}
+
+func (s *StubSMSProvider) SendSMS(to, message string) error {
+	return s.Sink.Send(Notification{Channel: "sms", To: to, Body: message})
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Storage holds uploaded files such as product images. Keys are
+// slash-separated paths like "products/<id>/<image>/original.jpg".
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Delete(key string) error
+	URL(key string) string
+}
+
+// NewStorage returns the storage selected by STORAGE_DRIVER: "s3" for an
+// S3-compatible bucket, or the local filesystem, which is the default.
+func NewStorage() Storage {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "s3":
+		return NewS3Storage()
+	default:
+		return NewLocalStorage()
+	}
+}
+
+// LocalStorage keeps files in a directory that the API serves itself under
+// BaseURL.
+type LocalStorage struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewLocalStorage reads UPLOADS_DIR (default "uploads") and UPLOADS_URL
+// (default "/uploads").
+func NewLocalStorage() *LocalStorage {
+	dir := os.Getenv("UPLOADS_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	baseURL := os.Getenv("UPLOADS_URL")
+	if baseURL == "" {
+		baseURL = "/uploads"
+	}
+	return &LocalStorage{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (l *LocalStorage) path(key string) string {
+	return filepath.Join(l.Dir, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+func (l *LocalStorage) Put(key string, data []byte, contentType string) error {
+	path := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (l *LocalStorage) Delete(key string) error {
+	err := os.Remove(l.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *LocalStorage) URL(key string) string {
+	return l.BaseURL + "/" + key
+}
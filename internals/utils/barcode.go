@@ -0,0 +1,46 @@
+package utils
+
+import "strings"
+
+// NormalizeBarcode strips the spaces and dashes scanners and people add.
+func NormalizeBarcode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(code))
+}
+
+// ValidBarcode reports whether code is an EAN-8, UPC-A or EAN-13 barcode with
+// a correct GS1 check digit.
+func ValidBarcode(code string) bool {
+	if n := len(code); n != 8 && n != 12 && n != 13 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+	}
+	// Weights alternate 3, 1, 3, ... from the digit left of the check digit
+	for i, weight := len(code)-2, 3; i >= 0; i, weight = i-1, 4-weight {
+		sum += int(code[i]-'0') * weight
+	}
+	return (10-sum%10)%10 == int(code[len(code)-1]-'0')
+}
+
+// BarcodeForms returns the forms a scanned barcode may be stored in: a UPC-A
+// code is also an EAN-13 with a leading zero, and the other way round.
+func BarcodeForms(code string) []string {
+	forms := []string{code}
+	if len(code) == 12 {
+		forms = append(forms, "0"+code)
+	}
+	if len(code) == 13 && code[0] == '0' {
+		forms = append(forms, code[1:])
+	}
+	return forms
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtKey is one key from JWT_KEYS_DIR. Every key can verify tokens; only
+// keys loaded from a private key file can sign.
+type jwtKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Public  crypto.PublicKey
+	Private crypto.Signer
+}
+
+var (
+	jwtKeys       map[string]*jwtKey
+	jwtSigningKey *jwtKey
+)
+
+// LoadJWTKeys reads the asymmetric signing keys. Each <kid>.pem file in
+// JWT_KEYS_DIR holds an RSA or Ed25519 private or public key, and
+// JWT_SIGNING_KID names the private key used to sign new tokens. When
+// JWT_KEYS_DIR is unset, tokens are signed with the HMAC JWT_SECRET instead.
+func LoadJWTKeys() error {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		jwtKeys = nil
+		jwtSigningKey = nil
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*jwtKey{}
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := loadJWTKey(file, kid)
+		if err != nil {
+			return fmt.Errorf("JWT key %s: %w", file, err)
+		}
+		keys[kid] = key
+	}
+
+	signingKID := os.Getenv("JWT_SIGNING_KID")
+	signing, ok := keys[signingKID]
+	if !ok {
+		return fmt.Errorf("JWT_SIGNING_KID %q does not match a key in %s", signingKID, dir)
+	}
+	if signing.Private == nil {
+		return fmt.Errorf("JWT signing key %q must be a private key", signingKID)
+	}
+
+	jwtKeys = keys
+	jwtSigningKey = signing
+	return nil
+}
+
+func loadJWTKey(file, kid string) (*jwtKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("not a PEM file")
+	}
+
+	key := &jwtKey{ID: kid}
+
+	switch block.Type {
+	case "PRIVATE KEY", "RSA PRIVATE KEY":
+		var parsed interface{}
+		if block.Type == "RSA PRIVATE KEY" {
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		} else {
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		key.Private = signer
+		key.Public = signer.Public()
+	case "PUBLIC KEY":
+		key.Public, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+
+	switch key.Public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	return key, nil
+}
+
+// signJWT signs claims with the current signing key, or with JWT_SECRET when
+// no asymmetric keys are configured.
+func signJWT(claims jwt.MapClaims) (string, error) {
+	if jwtSigningKey == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+	}
+
+	token := jwt.NewWithClaims(jwtSigningKey.Method, claims)
+	token.Header["kid"] = jwtSigningKey.ID
+	return token.SignedString(jwtSigningKey.Private)
+}
+
+// jwtKeyFunc picks the verification key named by the token's kid header and
+// rejects tokens whose algorithm does not match that key.
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	if jwtKeys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := jwtKeys[kid]
+	if !ok || token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.Public, nil
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set, for
+// services that verify our tokens.
+func JWKS() map[string]interface{} {
+	kids := []string{}
+	for kid := range jwtKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := []map[string]string{}
+	for _, kid := range kids {
+		key := jwtKeys[kid]
+		jwk := map[string]string{
+			"kid": key.ID,
+			"use": "sig",
+			"alg": key.Method.Alg(),
+		}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		keys = append(keys, jwk)
+	}
+
+	return map[string]interface{}{"keys": keys}
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidPhone = errors.New("Phone must be a valid Kenyan mobile number")
+
+// NormalizePhone converts a Kenyan mobile number in any of the usual forms
+// (0712345678, +254 712 345 678, 712345678) to the 2547XXXXXXXX/2541XXXXXXXX
+// form used by M-Pesa.
+func NormalizePhone(phone string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == ' ' || r == '-' || r == '+' || r == '(' || r == ')':
+			return -1
+		default:
+			return 'x'
+		}
+	}, phone)
+
+	switch {
+	case strings.HasPrefix(digits, "254") && len(digits) == 12:
+		digits = digits[3:]
+	case strings.HasPrefix(digits, "0") && len(digits) == 10:
+		digits = digits[1:]
+	}
+
+	if len(digits) != 9 || (digits[0] != '7' && digits[0] != '1') || strings.Contains(digits, "x") {
+		return "", ErrInvalidPhone
+	}
+
+	return "254" + digits, nil
+}
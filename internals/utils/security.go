@@ -2,7 +2,11 @@
 package utils
 
 import (
-	"os"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -18,24 +22,24 @@ func CheckPassword(p, hash string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(p)) == nil
 }
 
-func GenerateJWT(userID string, role string) string {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+const (
+	// AccessTokenTTL is kept short; clients renew through the refresh token
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+func GenerateJWT(userID string, role string, sessionID string) string {
+	t, _ := signJWT(jwt.MapClaims{
 		"user_id": userID,
 		"role":    role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+		"sid":     sessionID,
+		"exp":     time.Now().Add(AccessTokenTTL).Unix(),
 	})
-
-	t, _ := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 	return t
 }
 
 func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+	token, err := jwt.Parse(tokenString, jwtKeyFunc)
 
 	if err != nil {
 		return nil, err
@@ -52,3 +56,37 @@ func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
 
 	return claims, nil
 }
+
+// APIKeyPrefix starts every API key so they can be told apart from JWTs and
+// spotted by secret scanners.
+const APIKeyPrefix = "drx_"
+
+// GenerateRefreshToken returns a random opaque token for a session.
+func GenerateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GenerateNumericCode returns a random code of n decimal digits, for codes
+// users type in such as SMS verification codes. Each digit is drawn
+// uniformly from 0-9.
+func GenerateNumericCode(n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		b[i] = '0' + byte(digit.Int64())
+	}
+	return string(b), nil
+}
+
+// HashToken returns the hex SHA-256 of an opaque token for storage.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Slugify turns a display name such as "Soft Drinks" into a URL-safe
+// identifier ("soft-drinks").
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}